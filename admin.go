@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newAdminMux registers the operational endpoints served on the admin listener
+func newAdminMux(cfg *Config) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/config", adminConfigHandler)
+	mux.HandleFunc("/admin/config/reload", adminConfigReloadHandler)
+	mux.HandleFunc("/admin/loglevel", adminLogLevelHandler)
+	mux.HandleFunc("/admin/sampling", adminSamplingHandler)
+	registerDebugHandlers(mux)
+	registerStressHandlers(mux)
+	registerFaultHandlers(mux)
+	registerTraceStoreHandlers(mux)
+	return mux
+}
+
+// startAdminServer starts the admin listener in the background
+func startAdminServer(cfg *Config) (*http.Server, error) {
+	ac := cfg.Admin
+	hasTLS := ac.TLSCertFile != "" && ac.TLSKeyFile != ""
+	if ac.ClientCAFile != "" && !hasTLS {
+		return nil, errors.New("ADMIN_CLIENT_CA_FILE requires ADMIN_TLS_CERT_FILE and ADMIN_TLS_KEY_FILE")
+	}
+	if !ac.LoopbackOnly && ac.Token == "" && ac.ClientCAFile == "" {
+		return nil, errors.New("admin listener on a non-loopback address requires ADMIN_TOKEN or ADMIN_CLIENT_CA_FILE")
+	}
+
+	host := ""
+	if ac.LoopbackOnly {
+		host = "127.0.0.1"
+	}
+	srv := &http.Server{
+		Addr:              net.JoinHostPort(host, ac.Port),
+		Handler:           otelhttp.NewHandler(adminAuth(ac, authorize("/", newAdminMux(cfg))), "demo-app-admin"),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if ac.ClientCAFile != "" {
+		pem, err := os.ReadFile(ac.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read admin client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", ac.ClientCAFile)
+		}
+		srv.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", srv.Addr, err)
+	}
+	go func() {
+		var err error
+		if hasTLS {
+			err = srv.ServeTLS(ln, ac.TLSCertFile, ac.TLSKeyFile)
+		} else {
+			err = srv.Serve(ln)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[ERROR] Admin server stopped: %v", err)
+		}
+	}()
+	log.Printf("Admin API listening on %s (tls: %t, token: %t)", srv.Addr, hasTLS, ac.Token != "")
+	return srv, nil
+}
+
+// adminAuth requires a verified client certificate or the configured bearer token.
+// An API key or JWT is accepted only when RBAC is enabled and binds its subject to
+// the configured admin role. Without a token or client CA configured the listener relies on
+// being bound to loopback.
+func adminAuth(ac AdminConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p *Principal
+		cfg := rbac.Load()
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			p = &Principal{Subject: r.TLS.VerifiedChains[0][0].Subject.CommonName, Method: "mtls"}
+		} else if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && ac.Token != "" &&
+			subtle.ConstantTimeCompare([]byte(token), []byte(ac.Token)) == 1 {
+			// The token holder is the operator, RBAC must not lock it out
+			p = &Principal{Subject: "admin", Method: "token", Roles: []string{cfg.AdminRole}}
+		} else if a := auth.Load(); a.cfg.Enabled && cfg.Enabled {
+			if authenticated, err := a.authenticate(r); err == nil && contains(cfg.rolesFor(authenticated), cfg.AdminRole) {
+				p = authenticated
+			}
+		}
+
+		if p == nil && (ac.Token != "" || ac.ClientCAFile != "") {
+			log.Printf("[WARN] Unauthorized admin request, path: %s, remote: %s", r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSON(w, http.StatusUnauthorized, Response{
+				Status:    "error",
+				Message:   "unauthorized",
+				Timestamp: time.Now().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		if p != nil {
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("enduser.id", p.Subject))
+			r = r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func adminConfigHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := currentConfig.Load()
+	if cfg == nil {
+		cfg = loadConfig()
+	}
+	writeJSON(w, http.StatusOK, cfg.redacted())
+}
+
+func adminConfigReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if err := reloadConfig(); err != nil {
+		writeAdminError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	adminConfigHandler(w, r)
+}
+
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func adminLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req LogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		if err := setLogLevel(req.Level); err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Log level changed to %s", currentLogLevel())
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, LogLevelRequest{Level: currentLogLevel()})
+}
+
+// SamplingRequest changes the default ratio, the per-route rules or both
+type SamplingRequest struct {
+	Ratio *float64        `json:"ratio"`
+	Rules *[]SamplingRule `json:"rules,omitempty"`
+}
+
+func adminSamplingHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req SamplingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		if req.Ratio == nil && req.Rules == nil {
+			writeAdminError(w, http.StatusBadRequest, "ratio or rules is required")
+			return
+		}
+		if req.Ratio != nil && (*req.Ratio < 0 || *req.Ratio > 1) {
+			writeAdminError(w, http.StatusBadRequest, "ratio must be between 0 and 1")
+			return
+		}
+		if req.Rules != nil {
+			if err := validateSamplingRules(*req.Rules); err != nil {
+				writeAdminError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			sampler.SetRules(*req.Rules)
+			log.Printf("Trace sampling rules changed, %d rule(s)", len(*req.Rules))
+		}
+		if req.Ratio != nil {
+			sampler.SetRatio(*req.Ratio)
+			log.Printf("Trace sampling ratio changed to %g", sampler.Ratio())
+		}
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	ratio, rules := sampler.Ratio(), sampler.Rules()
+	writeJSON(w, http.StatusOK, SamplingRequest{Ratio: &ratio, Rules: &rules})
+}
+
+func writeAdminError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Response{
+		Status:    "error",
+		Message:   message,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
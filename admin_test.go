@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	setupAuth(t)
+	handler := adminAuth(AdminConfig{Token: "secret"}, authorize("/", newAdminMux(loadConfig())))
+
+	tests := []struct {
+		name      string
+		header    string
+		apiKey    string
+		rbac      bool
+		bindings  map[string][]string
+		adminRole string
+		expected  int
+	}{
+		{"no token", "", "", false, nil, "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", "", false, nil, "", http.StatusUnauthorized},
+		{"valid token", "Bearer secret", "", false, nil, "", http.StatusOK},
+		{"token under rbac", "Bearer secret", "", true, nil, "", http.StatusOK},
+		{"token under rbac with custom role", "Bearer secret", "", true, nil, "sre", http.StatusOK},
+		{"api key", "", "k-123", false, nil, "", http.StatusUnauthorized},
+		{"api key without admin role", "", "k-123", true, map[string][]string{"ci-bot": {"operator"}}, "", http.StatusUnauthorized},
+		{"api key with admin role", "", "k-123", true, map[string][]string{"ci-bot": {"admin"}}, "", http.StatusOK},
+		{"api key with custom admin role", "", "k-123", true, map[string][]string{"ci-bot": {"sre"}}, "sre", http.StatusOK},
+		{"api key with replaced admin role", "", "k-123", true, map[string][]string{"ci-bot": {"admin"}}, "sre", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig().RBAC
+			cfg.Enabled, cfg.Bindings = tt.rbac, tt.bindings
+			if tt.adminRole != "" {
+				cfg.AdminRole = tt.adminRole
+				cfg.Roles = map[string][]Permission{tt.adminRole: {{Methods: []string{"*"}, Path: "*"}}}
+			}
+			old := rbac.Load()
+			rbac.Store(&cfg)
+			defer rbac.Store(old)
+
+			req, err := http.NewRequest("GET", "/admin/config", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAdminConfigHandlerRedactsToken(t *testing.T) {
+	cfg := loadConfig()
+	cfg.Admin.Token = "secret"
+	currentConfig.Store(cfg)
+	defer currentConfig.Store(nil)
+
+	req, err := http.NewRequest("GET", "/admin/config", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(adminConfigHandler).ServeHTTP(rr, req)
+
+	if strings.Contains(rr.Body.String(), "secret") {
+		t.Errorf("config dump leaked admin token: %s", rr.Body.String())
+	}
+}
+
+func TestAdminLogLevelHandler(t *testing.T) {
+	defer setLogLevel("info")
+
+	req, err := http.NewRequest("PUT", "/admin/loglevel", strings.NewReader(`{"level":"warn"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(adminLogLevelHandler).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if currentLogLevel() != "warn" {
+		t.Errorf("log level not changed: got %v want %v", currentLogLevel(), "warn")
+	}
+
+	var buf bytes.Buffer
+	logger := log.New(&levelWriter{out: &buf}, "", 0)
+	logger.Printf("[INFO] dropped")
+	logger.Printf("[ERROR] kept")
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("level filter wrote unexpected output: %q", buf.String())
+	}
+}
+
+func TestAdminSamplingHandler(t *testing.T) {
+	defer sampler.SetRatio(1)
+	defer sampler.SetRules(nil)
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"valid ratio", `{"ratio":0.25}`, http.StatusOK},
+		{"out of range", `{"ratio":2}`, http.StatusBadRequest},
+		{"missing ratio", `{}`, http.StatusBadRequest},
+		{"rules", `{"rules":[{"route":"/api/*","ratio":0.5}]}`, http.StatusOK},
+		{"invalid rule", `{"rules":[{"route":"","ratio":0.5}]}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PUT", "/admin/sampling", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(adminSamplingHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+
+	var response SamplingRequest
+	rr := httptest.NewRecorder()
+	adminSamplingHandler(rr, httptest.NewRequest("GET", "/admin/sampling", nil))
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Errorf("failed to parse response: %v", err)
+	}
+	if response.Ratio == nil || *response.Ratio != 0.25 {
+		t.Errorf("unexpected sampling ratio: %v", response.Ratio)
+	}
+	if response.Rules == nil || len(*response.Rules) != 1 || (*response.Rules)[0].Route != "/api/*" {
+		t.Errorf("unexpected sampling rules: %v", response.Rules)
+	}
+}
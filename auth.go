@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string   `json:"subject"`
+	Method  string   `json:"method"` // apikey or jwt
+	Scopes  []string `json:"scopes,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+}
+
+type principalKey struct{}
+
+// principalFromContext returns the authenticated caller, or nil for anonymous requests
+func principalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// APIKey is one entry of the API keys file
+type APIKey struct {
+	Key     string   `json:"key"`
+	Subject string   `json:"subject"`
+	Scopes  []string `json:"scopes,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+}
+
+// authenticator verifies API keys and JWTs loaded from the configured files
+type authenticator struct {
+	cfg     AuthConfig
+	apiKeys []APIKey
+	keys    map[string]crypto.PublicKey // JWKS keys by kid
+}
+
+var auth atomic.Pointer[authenticator]
+
+func init() {
+	auth.Store(&authenticator{})
+}
+
+// newAuthenticator loads the API keys and JWKS files named in cfg
+func newAuthenticator(cfg AuthConfig) (*authenticator, error) {
+	a := &authenticator{cfg: cfg, keys: make(map[string]crypto.PublicKey)}
+	if cfg.APIKeysFile != "" {
+		data, err := os.ReadFile(cfg.APIKeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys file: %w", err)
+		}
+		if err := json.Unmarshal(data, &a.apiKeys); err != nil {
+			return nil, fmt.Errorf("failed to parse API keys file: %w", err)
+		}
+	}
+	if cfg.JWKSFile != "" {
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		if a.keys, err = parseJWKS(data); err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS file: %w", err)
+		}
+	}
+	return a, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS decodes the RSA and P-256 EC public keys of a JWK set
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err := errors.Join(err1, err2); err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				return nil, fmt.Errorf("key %q: unsupported curve %q", k.Kid, k.Crv)
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if err := errors.Join(err1, err2); err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		default:
+			return nil, fmt.Errorf("key %q: unsupported key type %q", k.Kid, k.Kty)
+		}
+	}
+	return keys, nil
+}
+
+var errNoCredentials = errors.New("no credentials")
+
+// streamCredentialCookie carries an API key or JWT for /api/events, since the
+// dashboard's EventSource cannot set headers. A query parameter would end up in spans.
+const streamCredentialCookie = "demo_app_credential"
+
+// authenticate resolves the caller from X-API-Key, an Authorization bearer JWT or,
+// on the event stream only, the credential cookie
+func (a *authenticator) authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		if p := a.apiKey(key); p != nil {
+			return p, nil
+		}
+		return nil, errors.New("invalid API key")
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return a.verifyJWT(strings.TrimSpace(token), time.Now())
+	}
+	if c, err := r.Cookie(streamCredentialCookie); err == nil && r.URL.Path == "/api/events" {
+		credential, err := url.QueryUnescape(c.Value)
+		if err != nil || credential == "" {
+			return nil, errors.New("invalid credential cookie")
+		}
+		if p := a.apiKey(credential); p != nil {
+			return p, nil
+		}
+		return a.verifyJWT(credential, time.Now())
+	}
+	return nil, errNoCredentials
+}
+
+func (a *authenticator) apiKey(key string) *Principal {
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+			return &Principal{Subject: k.Subject, Method: "apikey", Scopes: k.Scopes, Roles: k.Roles}
+		}
+	}
+	return nil
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Roles     []string        `json:"roles"`
+}
+
+// verifyJWT checks the signature (HS256, RS256 or ES256) and the registered claims of a compact JWT
+func (a *authenticator) verifyJWT(token string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signed)
+
+	switch header.Alg {
+	case "HS256":
+		if a.cfg.JWTSecret == "" {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, []byte(a.cfg.JWTSecret))
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, errors.New("invalid token signature")
+		}
+	case "RS256":
+		pub, ok := a.keys[header.Kid].(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unknown RSA key %q", header.Kid)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, errors.New("invalid token signature")
+		}
+	case "ES256":
+		pub, ok := a.keys[header.Kid].(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unknown EC key %q", header.Kid)
+		}
+		if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return nil, errors.New("invalid token signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	skew := a.cfg.ClockSkew
+	if claims.ExpiresAt != nil && now.After(time.Unix(*claims.ExpiresAt, 0).Add(skew)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Before(time.Unix(*claims.NotBefore, 0).Add(-skew)) {
+		return nil, errors.New("token not yet valid")
+	}
+	if a.cfg.Issuer != "" && claims.Issuer != a.cfg.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if a.cfg.Audience != "" && !hasAudience(claims.Audience, a.cfg.Audience) {
+		return nil, errors.New("token audience mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Principal{Subject: claims.Subject, Method: "jwt", Scopes: strings.Fields(claims.Scope), Roles: claims.Roles}, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether the aud claim, a string or an array, contains want
+func hasAudience(raw json.RawMessage, want string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == want
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		for _, aud := range list {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requirementFor returns the auth requirement for an /api path
+func (cfg *AuthConfig) requirementFor(path string) AuthRequirement {
+	if req, ok := cfg.Routes[path]; ok {
+		return req
+	}
+	return cfg.Default
+}
+
+// check reports why p does not satisfy the requirement, or "" when it does
+func (req AuthRequirement) check(p *Principal) string {
+	if len(req.Methods) > 0 && !contains(req.Methods, p.Method) {
+		return fmt.Sprintf("authentication method %q is not accepted for this route", p.Method)
+	}
+	for _, scope := range req.Scopes {
+		if !contains(p.Scopes, scope) {
+			return fmt.Sprintf("missing required scope %q", scope)
+		}
+	}
+	return ""
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateAPI identifies callers of /api routes and enforces the per-route requirements
+func authenticateAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := auth.Load()
+		if !a.cfg.Enabled || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, code, err := a.admit(r)
+		if err != nil {
+			if code == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="demo-app"`)
+			}
+			writeProblem(w, r, code, err.Error())
+			return
+		}
+		if p != nil {
+			r = r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// admit checks r against the requirement of its route. It returns the principal,
+// nil for an allowed anonymous caller, or the status and reason to reject it with.
+func (a *authenticator) admit(r *http.Request) (*Principal, int, error) {
+	span := trace.SpanFromContext(r.Context())
+	req := a.cfg.requirementFor(r.URL.Path)
+	p, err := a.authenticate(r)
+	switch {
+	case err == errNoCredentials && !req.Required:
+		return nil, http.StatusOK, nil
+	case err != nil:
+		span.AddEvent("auth.failed", trace.WithAttributes(attribute.String("auth.error", err.Error())))
+		log.Printf("[WARN] Authentication failed, path: %s, reason: %v, traceId: %s", r.URL.Path, err, getTraceID(r.Context()))
+		return nil, http.StatusUnauthorized, err
+	}
+
+	span.SetAttributes(
+		attribute.String("enduser.id", p.Subject),
+		attribute.String("enduser.scope", strings.Join(p.Scopes, " ")),
+		attribute.String("auth.method", p.Method),
+	)
+	if reason := req.check(p); reason != "" {
+		log.Printf("[WARN] Access denied, path: %s, subject: %s, reason: %s, traceId: %s", r.URL.Path, p.Subject, reason, getTraceID(r.Context()))
+		return nil, http.StatusForbidden, errors.New(reason)
+	}
+	return p, http.StatusOK, nil
+}
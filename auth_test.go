@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signJWT builds a compact JWT signed with an HMAC secret, RSA or P-256 key
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	enc := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := enc(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func setupAuth(t *testing.T) (*rsa.PrivateKey, *ecdsa.PrivateKey) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa-1","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec-1","crv":"P-256","x":%q,"y":%q}]}`,
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))))
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "jwks.json"), jwks)
+	writeFile(t, filepath.Join(dir, "keys.json"), `[{"key":"k-123","subject":"ci-bot","scopes":["echo"]}]`)
+
+	a, err := newAuthenticator(AuthConfig{
+		Enabled:     true,
+		APIKeysFile: filepath.Join(dir, "keys.json"),
+		JWKSFile:    filepath.Join(dir, "jwks.json"),
+		JWTSecret:   "hs-secret",
+		Audience:    "demo-app",
+		Default:     AuthRequirement{Required: true},
+		Routes: map[string]AuthRequirement{
+			"/api/hello": {Required: false},
+			"/api/echo":  {Required: true, Scopes: []string{"echo"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := auth.Load()
+	auth.Store(a)
+	t.Cleanup(func() { auth.Store(old) })
+	return rsaKey, ecKey
+}
+
+func TestAuthenticateAPI(t *testing.T) {
+	rsaKey, ecKey := setupAuth(t)
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := func(sub, scope string, exp int64) map[string]interface{} {
+		return map[string]interface{}{"sub": sub, "aud": []string{"demo-app"}, "exp": exp, "scope": scope}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		value    string
+		expected int
+		subject  string
+	}{
+		{"anonymous optional route", "/api/hello", "", "", http.StatusOK, ""},
+		{"anonymous required route", "/api/random", "", "", http.StatusUnauthorized, ""},
+		{"not an api route", "/version", "", "", http.StatusOK, ""},
+		{"api key", "/api/echo", "X-API-Key", "k-123", http.StatusOK, "ci-bot"},
+		{"invalid api key", "/api/hello", "X-API-Key", "nope", http.StatusUnauthorized, ""},
+		{"hs256", "/api/random", "Authorization", "Bearer " + signJWT(t, "HS256", "", []byte("hs-secret"), claims("alice", "", exp)), http.StatusOK, "alice"},
+		{"rs256", "/api/echo", "Authorization", "Bearer " + signJWT(t, "RS256", "rsa-1", rsaKey, claims("bob", "echo", exp)), http.StatusOK, "bob"},
+		{"es256", "/api/echo", "Authorization", "Bearer " + signJWT(t, "ES256", "ec-1", ecKey, claims("carol", "read echo", exp)), http.StatusOK, "carol"},
+		{"missing scope", "/api/echo", "Authorization", "Bearer " + signJWT(t, "RS256", "rsa-1", rsaKey, claims("bob", "read", exp)), http.StatusForbidden, ""},
+		{"expired", "/api/random", "Authorization", "Bearer " + signJWT(t, "ES256", "ec-1", ecKey, claims("carol", "", time.Now().Add(-time.Hour).Unix())), http.StatusUnauthorized, ""},
+		{"wrong secret", "/api/random", "Authorization", "Bearer " + signJWT(t, "HS256", "", []byte("other"), claims("alice", "", exp)), http.StatusUnauthorized, ""},
+		{"stream cookie", "/api/events", "Cookie", streamCredentialCookie + "=k-123", http.StatusOK, "ci-bot"},
+		{"stream cookie jwt", "/api/events", "Cookie", streamCredentialCookie + "=" + signJWT(t, "HS256", "", []byte("hs-secret"), claims("alice", "", exp)), http.StatusOK, "alice"},
+		{"invalid stream cookie", "/api/events", "Cookie", streamCredentialCookie + "=nope", http.StatusUnauthorized, ""},
+		{"cookie outside the stream", "/api/random", "Cookie", streamCredentialCookie + "=k-123", http.StatusUnauthorized, ""},
+		{"unknown kid", "/api/random", "Authorization", "Bearer " + signJWT(t, "RS256", "rsa-2", rsaKey, claims("bob", "", exp)), http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			var subject string
+			handler := authenticateAPI(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if p := principalFromContext(r.Context()); p != nil {
+					subject = p.Subject
+				}
+			}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v (%s)", status, tt.expected, rr.Body.String())
+			}
+			if subject != tt.subject {
+				t.Errorf("unexpected subject: got %q want %q", subject, tt.subject)
+			}
+		})
+	}
+}
+
+func TestEchoHandlerReportsSubject(t *testing.T) {
+	setupAuth(t)
+
+	req := httptest.NewRequest("GET", "/api/echo?message=hi", nil)
+	req.Header.Set("X-API-Key", "k-123")
+	rr := httptest.NewRecorder()
+	authenticateAPI(http.HandlerFunc(echoHandler)).ServeHTTP(rr, req)
+
+	var response EchoResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if response.Subject != "ci-bot" {
+		t.Errorf("unexpected subject: got %q want %q", response.Subject, "ci-bot")
+	}
+	if response.Headers["X-Api-Key"] != "[redacted]" {
+		t.Errorf("api key echoed back: %q", response.Headers["X-Api-Key"])
+	}
+}
+
+func TestNewAuthenticatorMissingFile(t *testing.T) {
+	_, err := newAuthenticator(AuthConfig{APIKeysFile: filepath.Join(os.TempDir(), "does-not-exist.json")})
+	if err == nil {
+		t.Error("expected an error for a missing API keys file")
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// encoder is implemented by every supported compressor and lets them be pooled
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+type zstdEncoder struct{ *zstd.Encoder }
+
+func (z zstdEncoder) Reset(w io.Writer) { z.Encoder.Reset(w) }
+
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} { return gzip.NewWriter(io.Discard) }},
+	"deflate": {New: func() interface{} {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return w
+	}},
+	"br": {New: func() interface{} { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }},
+	"zstd": {New: func() interface{} {
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return zstdEncoder{w}
+	}},
+}
+
+var compressionConfig atomic.Pointer[CompressionConfig]
+
+func init() {
+	compressionConfig.Store(&CompressionConfig{})
+}
+
+type encodingStats struct {
+	responses, bytesIn, bytesOut atomic.Int64
+}
+
+var compressionStats = func() map[string]*encodingStats {
+	m := make(map[string]*encodingStats)
+	for enc := range encoderPools {
+		m[enc] = &encodingStats{}
+	}
+	return m
+}()
+
+type EncodingMetrics struct {
+	Responses int64   `json:"responses"`
+	BytesIn   int64   `json:"bytesIn"`
+	BytesOut  int64   `json:"bytesOut"`
+	Ratio     float64 `json:"ratio"` // bytesOut / bytesIn
+}
+
+type CompressionMetrics struct {
+	Enabled    bool                       `json:"enabled"`
+	ByEncoding map[string]EncodingMetrics `json:"byEncoding"`
+}
+
+func compressionMetrics() CompressionMetrics {
+	m := CompressionMetrics{
+		Enabled:    compressionConfig.Load().Enabled,
+		ByEncoding: make(map[string]EncodingMetrics, len(compressionStats)),
+	}
+	for enc, s := range compressionStats {
+		em := EncodingMetrics{Responses: s.responses.Load(), BytesIn: s.bytesIn.Load(), BytesOut: s.bytesOut.Load()}
+		if em.BytesIn > 0 {
+			em.Ratio = math.Round(float64(em.BytesOut)/float64(em.BytesIn)*1000) / 1000
+		}
+		m.ByEncoding[enc] = em
+	}
+	return m
+}
+
+// negotiateEncoding picks the encoding with the highest q-value in Accept-Encoding,
+// breaking ties by the server preference order
+func negotiateEncoding(acceptEncoding string, preferred []string) string {
+	type candidate struct {
+		name string
+		q    float64
+		rank int
+	}
+	q := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[name] = weight
+	}
+
+	var candidates []candidate
+	for rank, enc := range preferred {
+		weight, ok := q[enc]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > 0 {
+			if _, supported := encoderPools[enc]; supported {
+				candidates = append(candidates, candidate{enc, weight, rank})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return candidates[i].rank < candidates[j].rank
+	})
+	return candidates[0].name
+}
+
+// compressible reports whether a content type is on the allowlist (prefix match).
+// Event streams never are: each event must reach the client as soon as it is flushed.
+func (cfg *CompressionConfig) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	if mediaType == "text/event-stream" {
+		return false
+	}
+	for _, allowed := range cfg.ContentTypes {
+		if strings.HasPrefix(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponses encodes response bodies according to Accept-Encoding
+func compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := compressionConfig.Load()
+		if !cfg.Enabled || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: w,
+			r:              r,
+			cfg:            cfg,
+			encoding:       negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings),
+			status:         http.StatusOK,
+		}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the start of the body until it knows whether the
+// response is large enough and of an allowed type to be worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	cfg      *CompressionConfig
+	encoding string
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	hijacked    bool
+	buf         []byte
+	enc         encoder
+	out         *countingWriter
+	bytesIn     int64
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) >= cw.cfg.MinSize {
+			if err := cw.decide(false); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		cw.bytesIn += int64(len(p))
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide commits the headers, choosing compressed or identity encoding.
+// Streaming responses are compressed regardless of the minimum size.
+func (cw *compressWriter) decide(streaming bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	eligible := cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && cw.cfg.compressible(h.Get("Content-Type"))
+	if eligible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if eligible && cw.encoding != "" && (streaming || len(cw.buf) >= cw.cfg.MinSize) {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		// A strong validator must change with the representation
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.out = &countingWriter{w: cw.ResponseWriter}
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.out)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := cw.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) Flush() {
+	if cw.hijacked {
+		return
+	}
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	cw.hijacked = true
+	return hj.Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) close() {
+	if cw.hijacked {
+		return
+	}
+	if !cw.decided {
+		if !cw.wroteHeader {
+			cw.wroteHeader = true
+		}
+		cw.decide(false)
+	}
+	if cw.enc == nil {
+		return
+	}
+	cw.enc.Close()
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+
+	stats := compressionStats[cw.encoding]
+	stats.responses.Add(1)
+	stats.bytesIn.Add(cw.bytesIn)
+	stats.bytesOut.Add(cw.out.n)
+	trace.SpanFromContext(cw.r.Context()).SetAttributes(
+		attribute.String("http.response.content_encoding", cw.encoding),
+		attribute.Int64("http.response.uncompressed_size", cw.bytesIn),
+		attribute.Int64("http.response.compressed_size", cw.out.n),
+	)
+}
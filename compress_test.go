@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	preferred := []string{"zstd", "br", "gzip", "deflate"}
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"br;q=0, gzip;q=0.1", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0.5, zstd;q=0", "br"},
+		{"GZIP", "gzip"},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.acceptEncoding, preferred); got != tt.expected {
+			t.Errorf("negotiateEncoding(%q): got %q want %q", tt.acceptEncoding, got, tt.expected)
+		}
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		r = gr
+	case "deflate":
+		r = flate.NewReader(bytes.NewReader(body))
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return body
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decode %s: %v", encoding, err)
+	}
+	return out
+}
+
+func TestCompressResponses(t *testing.T) {
+	cfg := loadConfig().Compression
+	cfg.Enabled = true
+	old := compressionConfig.Load()
+	compressionConfig.Store(&cfg)
+	defer compressionConfig.Store(old)
+
+	large := strings.Repeat(`{"message":"hello"}`, 200)
+	handler := compressResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":true}`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(large))
+		case "/encoded":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write([]byte(large))
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(large))
+		case "/status":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte(large))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			// Written in small chunks to exercise buffering up to the threshold
+			for i := 0; i < len(large); i += 100 {
+				w.Write([]byte(large[i:min(i+100, len(large))]))
+			}
+		}
+	}))
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		status         int
+		encoding       string
+		vary           bool
+	}{
+		{"gzip", "/", "gzip", http.StatusOK, "gzip", true},
+		{"deflate", "/", "deflate", http.StatusOK, "deflate", true},
+		{"brotli", "/", "gzip, br", http.StatusOK, "br", true},
+		{"zstd", "/", "gzip, br, zstd", http.StatusOK, "zstd", true},
+		{"identity", "/", "", http.StatusOK, "", true},
+		{"below threshold", "/small", "gzip", http.StatusOK, "", true},
+		{"type not allowed", "/image", "gzip", http.StatusOK, "", false},
+		{"event stream", "/events", "gzip", http.StatusOK, "", false},
+		{"already encoded", "/encoded", "br", http.StatusOK, "gzip", false},
+		{"status preserved", "/status", "gzip", http.StatusTeapot, "gzip", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+			if got := rr.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("unexpected Content-Encoding: got %q want %q", got, tt.encoding)
+			}
+			if got := rr.Header().Get("Vary") == "Accept-Encoding"; got != tt.vary {
+				t.Errorf("unexpected Vary: %v", rr.Header().Values("Vary"))
+			}
+			if tt.path == "/" || tt.path == "/status" {
+				if body := decode(t, tt.encoding, rr.Body.Bytes()); string(body) != large {
+					t.Errorf("body did not round-trip through %q", tt.encoding)
+				}
+			}
+		})
+	}
+
+	m := compressionMetrics()
+	if gz := m.ByEncoding["gzip"]; gz.Responses < 2 || gz.Ratio <= 0 || gz.Ratio >= 1 {
+		t.Errorf("unexpected gzip metrics: %+v", gz)
+	}
+}
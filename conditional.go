@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var cacheConfig atomic.Pointer[CacheConfig]
+
+func init() {
+	cacheConfig.Store(&CacheConfig{})
+}
+
+// makeETag returns a quoted entity tag derived from the given parts
+func makeETag(weak bool, parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	tag := `"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+type etagSourceKey struct{}
+
+// setETagSource makes the route's entity tag, if it has one, derive from parts
+// instead of the whole body. Handlers use it when the body carries fields, such
+// as a timestamp, that change without the representation changing.
+func setETagSource(ctx context.Context, parts ...[]byte) {
+	if source, ok := ctx.Value(etagSourceKey{}).(*[][]byte); ok {
+		*source = parts
+	}
+}
+
+// etagMatches reports whether an If-None-Match header matches etag using the
+// weak comparison of RFC 9110, which ignores the W/ prefix on either side
+func etagMatches(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified evaluates If-None-Match, then If-Modified-Since when no entity tags were sent
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		return etag != "" && etagMatches(inm, etag)
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lm.Truncate(time.Second).After(ims)
+}
+
+// handleConditional sets per-route Cache-Control and validators, and answers
+// conditional GET and HEAD requests with 304 Not Modified
+func handleConditional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := cacheConfig.Load()
+		policy, ok := cfg.Routes[r.URL.Path]
+		if !cfg.Enabled || !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if policy.CacheControl != "" {
+			w.Header().Set("Cache-Control", policy.CacheControl)
+		}
+		if policy.ETag == "" && !policy.LastModified {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var source [][]byte
+		rec := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), etagSourceKey{}, &source)))
+
+		h := w.Header()
+		if rec.status == http.StatusOK {
+			if source == nil {
+				source = [][]byte{rec.body.Bytes()}
+			}
+			if policy.ETag != "" && h.Get("ETag") == "" {
+				h.Set("ETag", makeETag(policy.ETag == "weak", source...))
+			}
+			if policy.LastModified && h.Get("Last-Modified") == "" {
+				h.Set("Last-Modified", startTime.UTC().Format(http.TimeFormat))
+			}
+
+			conditional := r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != ""
+			span := trace.SpanFromContext(r.Context())
+			if conditional && notModified(r, h) {
+				span.SetAttributes(attribute.String("http.cache.result", "not_modified"))
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if conditional {
+				span.SetAttributes(attribute.String("http.cache.result", "modified"))
+			}
+		}
+
+		h.Set("Content-Length", strconv.Itoa(rec.body.Len()))
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// bufferedResponse holds a handler's output so validators can be computed from the full body
+type bufferedResponse struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.wroteHeader = true
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
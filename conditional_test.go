@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleConditional(t *testing.T) {
+	cfg := loadConfig().Cache
+	cfg.Enabled = true
+	old := cacheConfig.Load()
+	cacheConfig.Store(&cfg)
+	defer cacheConfig.Store(old)
+
+	handler := handleConditional(http.HandlerFunc(versionHandler))
+
+	req := httptest.NewRequest("GET", "/version", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" || etag[0] != '"' {
+		t.Fatalf("unexpected first response: %d, ETag %q", rr.Code, etag)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("unexpected Cache-Control: %q", got)
+	}
+	lastModified := rr.Header().Get("Last-Modified")
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected int
+	}{
+		{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+		{"weak form of etag", "If-None-Match", "W/" + etag, http.StatusNotModified},
+		{"etag in list", "If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"wildcard", "If-None-Match", "*", http.StatusNotModified},
+		{"stale etag", "If-None-Match", `"other"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", lastModified, http.StatusNotModified},
+		{"modified since", "If-Modified-Since", startTime.Add(-time.Hour).UTC().Format(http.TimeFormat), http.StatusOK},
+		{"invalid date", "If-Modified-Since", "yesterday", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/version", nil)
+			req.Header.Set(tt.header, tt.value)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+			if tt.expected == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Errorf("304 response has a body: %q", rr.Body.String())
+			}
+			if got := rr.Header().Get("ETag"); got != etag {
+				t.Errorf("unexpected ETag: got %q want %q", got, etag)
+			}
+		})
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req = httptest.NewRequest("GET", "/version", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 when entity tags do not match, got %d", rr.Code)
+	}
+}
+
+func TestInfoWeakETag(t *testing.T) {
+	cfg := loadConfig().Cache
+	cfg.Enabled = true
+	old := cacheConfig.Load()
+	cacheConfig.Store(&cfg)
+	defer cacheConfig.Store(old)
+
+	handler := handleConditional(http.HandlerFunc(infoHandler))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/info", nil))
+	etag := rr.Header().Get("ETag")
+	if len(etag) < 2 || etag[:2] != "W/" {
+		t.Fatalf("expected a weak ETag, got %q", etag)
+	}
+
+	req := httptest.NewRequest("GET", "/api/info", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching weak ETag, got %d", rr.Code)
+	}
+
+	// Turning caching off removes the validator along with the rest of the policy
+	cfg.Enabled = false
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != "" {
+		t.Errorf("expected 200 without an ETag when caching is disabled, got %d and %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
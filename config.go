@@ -0,0 +1,574 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the runtime configuration of the application
+type Config struct {
+	File         string                `json:"configFile,omitempty"`
+	Port         string                `json:"port"`
+	Environment  string                `json:"environment"`
+	ServiceName  string                `json:"serviceName"`
+	OTLPEndpoint string                `json:"otlpEndpoint"`
+	LogLevel     string                `json:"logLevel"`
+	SampleRatio  float64               `json:"sampleRatio"`
+	Sampling     []SamplingRule        `json:"samplingRules,omitempty"` // per-route overrides of SampleRatio
+	Admin        AdminConfig           `json:"admin"`
+	Stress       StressConfig          `json:"stress"`
+	Concurrency  ConcurrencyConfig     `json:"concurrency"`
+	RateLimit    RateLimitConfig       `json:"rateLimit"`
+	Auth         AuthConfig            `json:"auth"`
+	RBAC         RBACConfig            `json:"rbac"`
+	CORS         CORSConfig            `json:"cors"`
+	Compression  CompressionConfig     `json:"compression"`
+	Cache        CacheConfig           `json:"cache"`
+	Security     SecurityHeadersConfig `json:"securityHeaders"`
+	TLS          TLSConfig             `json:"tls"`
+	H2C          bool                  `json:"h2c"`
+	Socket       string                `json:"socket,omitempty"` // also serve plain HTTP on this Unix socket
+	Shutdown     time.Duration         `json:"shutdownTimeout"`  // how long SIGTERM waits for in-flight requests
+	GRPC         GRPCConfig            `json:"grpc"`
+	WebSocket    WebSocketConfig       `json:"webSocket"`
+	Events       EventsConfig          `json:"events"`
+	Dashboard    DashboardConfig       `json:"dashboard"`
+	I18n         I18nConfig            `json:"i18n"`
+	Proxy        ProxyConfig           `json:"proxy"`
+	Topology     TopologyConfig        `json:"topology"`
+}
+
+// AdminConfig configures the separate listener for operational endpoints
+type AdminConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Port         string `json:"port"`
+	LoopbackOnly bool   `json:"loopbackOnly"`
+	Token        string `json:"token,omitempty"`
+	TLSCertFile  string `json:"tlsCertFile,omitempty"`
+	TLSKeyFile   string `json:"tlsKeyFile,omitempty"`
+	ClientCAFile string `json:"clientCaFile,omitempty"`
+	TraceStore   int    `json:"traceStoreSpans"` // finished spans kept for /admin/traces, 0 disables
+}
+
+// StressConfig caps the resources that stress sessions may consume in total
+type StressConfig struct {
+	MaxCores      int           `json:"maxCores"`
+	MaxMemoryMB   int           `json:"maxMemoryMB"`
+	MaxGoroutines int           `json:"maxGoroutines"`
+	MaxDiskMB     int           `json:"maxDiskMB"`
+	MaxDuration   time.Duration `json:"maxDuration"`
+}
+
+// ConcurrencyConfig bounds in-flight requests on the public listener; MaxInFlight 0 disables it
+type ConcurrencyConfig struct {
+	MaxInFlight     int               `json:"maxInFlight"`
+	MaxQueue        int               `json:"maxQueue"`
+	QueueTimeout    time.Duration     `json:"queueTimeout"`
+	RetryAfter      time.Duration     `json:"retryAfter"`
+	RoutePriorities map[string]string `json:"routePriorities,omitempty"`
+}
+
+// RateLimitConfig defines per-client token-bucket limits for the public listener
+type RateLimitConfig struct {
+	Enabled           bool                       `json:"enabled"`
+	KeyBy             string                     `json:"keyBy"` // ip, apikey or header
+	Header            string                     `json:"header,omitempty"`
+	TrustForwardedFor bool                       `json:"trustForwardedFor"`
+	Default           RateLimitPolicy            `json:"default"`
+	Routes            map[string]RateLimitPolicy `json:"routes,omitempty"`
+}
+
+// RateLimitPolicy allows Burst requests at once, refilled at RequestsPerSecond
+type RateLimitPolicy struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// AuthConfig configures API key and JWT authentication for /api routes
+type AuthConfig struct {
+	Enabled     bool                       `json:"enabled"`
+	APIKeysFile string                     `json:"apiKeysFile,omitempty"`
+	JWTSecret   string                     `json:"jwtSecret,omitempty"` // HS256 shared secret
+	JWKSFile    string                     `json:"jwksFile,omitempty"`  // RS256/ES256 public keys
+	Issuer      string                     `json:"issuer,omitempty"`
+	Audience    string                     `json:"audience,omitempty"`
+	ClockSkew   time.Duration              `json:"clockSkew"`
+	Default     AuthRequirement            `json:"default"`
+	Routes      map[string]AuthRequirement `json:"routes,omitempty"`
+}
+
+// AuthRequirement describes what a route expects from the caller
+type AuthRequirement struct {
+	Required bool     `json:"required"`
+	Methods  []string `json:"methods,omitempty"` // apikey, jwt; empty accepts both
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// RBACConfig grants permissions to roles and binds subjects to roles.
+// The "anonymous" subject binds roles for unauthenticated callers, and callers
+// holding AdminRole may use the admin listener.
+type RBACConfig struct {
+	Enabled   bool                    `json:"enabled"`
+	Roles     map[string][]Permission `json:"roles"`
+	Bindings  map[string][]string     `json:"bindings"`
+	AdminRole string                  `json:"adminRole"`
+}
+
+// Permission allows Methods on Path; "*" matches any method, a trailing "*" matches a path prefix
+type Permission struct {
+	Methods []string `json:"methods"`
+	Path    string   `json:"path"`
+}
+
+// CORSConfig holds the default cross-origin policy and per-route overrides
+type CORSConfig struct {
+	Enabled bool                  `json:"enabled"`
+	Default CORSPolicy            `json:"default"`
+	Routes  map[string]CORSPolicy `json:"routes,omitempty"`
+}
+
+type CORSPolicy struct {
+	AllowedOrigins   []string `json:"allowedOrigins"` // exact, "*" or "https://*.example.com"
+	AllowedMethods   []string `json:"allowedMethods"`
+	AllowedHeaders   []string `json:"allowedHeaders,omitempty"`
+	ExposedHeaders   []string `json:"exposedHeaders,omitempty"`
+	AllowCredentials bool     `json:"allowCredentials"`
+	MaxAge           int      `json:"maxAge"` // seconds
+}
+
+// CompressionConfig controls response compression on the public listener
+type CompressionConfig struct {
+	Enabled      bool     `json:"enabled"`
+	MinSize      int      `json:"minSize"`      // bytes; smaller bodies are sent as-is
+	ContentTypes []string `json:"contentTypes"` // media type prefixes
+	Encodings    []string `json:"encodings"`    // server preference order
+}
+
+// CacheConfig sets caching headers and validators for GET and HEAD requests by route
+type CacheConfig struct {
+	Enabled bool                   `json:"enabled"`
+	Routes  map[string]CachePolicy `json:"routes,omitempty"`
+}
+
+type CachePolicy struct {
+	CacheControl string `json:"cacheControl,omitempty"`
+	ETag         string `json:"etag,omitempty"` // strong, weak or empty for none
+	LastModified bool   `json:"lastModified"`   // use the process start time
+}
+
+// SecurityHeadersConfig sets the browser hardening headers; "{nonce}" in the
+// content security policy is replaced with a fresh nonce on every request
+type SecurityHeadersConfig struct {
+	Enabled               bool   `json:"enabled"`
+	HSTSMaxAge            int    `json:"hstsMaxAge"` // seconds, 0 disables
+	HSTSIncludeSubdomains bool   `json:"hstsIncludeSubdomains"`
+	ContentSecurityPolicy string `json:"contentSecurityPolicy,omitempty"`
+	CSPReportOnly         bool   `json:"cspReportOnly"`
+	ReferrerPolicy        string `json:"referrerPolicy,omitempty"`
+	PermissionsPolicy     string `json:"permissionsPolicy,omitempty"`
+	FrameOptions          string `json:"frameOptions,omitempty"`
+}
+
+// TLSConfig serves the public listener over HTTPS; rotated files are picked up every ReloadInterval
+type TLSConfig struct {
+	Enabled        bool          `json:"enabled"`
+	CertFile       string        `json:"certFile,omitempty"`
+	KeyFile        string        `json:"keyFile,omitempty"`
+	SelfSigned     bool          `json:"selfSigned"`
+	ClientCAFile   string        `json:"clientCaFile,omitempty"`
+	ClientAuth     string        `json:"clientAuth"` // none, request, verify-if-given or require
+	ReloadInterval time.Duration `json:"reloadInterval"`
+}
+
+// GRPCConfig configures the gRPC listener that mirrors the HTTP API
+type GRPCConfig struct {
+	Enabled bool   `json:"enabled"`
+	Port    string `json:"port"`
+}
+
+// WebSocketConfig bounds /ws connections; clients idle longer than IdleTimeout are disconnected
+type WebSocketConfig struct {
+	MaxConnections  int           `json:"maxConnections"`
+	IdleTimeout     time.Duration `json:"idleTimeout"`
+	PingInterval    time.Duration `json:"pingInterval"`
+	MaxMessageBytes int64         `json:"maxMessageBytes"`
+	AllowedOrigins  []string      `json:"allowedOrigins,omitempty"` // in addition to same-origin
+}
+
+// EventsConfig tunes the /api/events server-sent event stream
+type EventsConfig struct {
+	BufferSize      int           `json:"bufferSize"` // events kept for Last-Event-ID resume
+	MetricsInterval time.Duration `json:"metricsInterval"`
+	Heartbeat       time.Duration `json:"heartbeat"`
+}
+
+// DashboardConfig configures the root page. TraceURL links a trace ID to the
+// tracing UI, with {traceId} replaced; empty shows plain IDs.
+type DashboardConfig struct {
+	TraceURL string `json:"traceUrl,omitempty"`
+}
+
+// I18nConfig sets the locale used when neither ?lang= nor Accept-Language matches a
+// catalog. Empty keeps the original text from locales/default.json.
+type I18nConfig struct {
+	DefaultLocale string `json:"defaultLocale"`
+}
+
+// ProxyConfig restricts the outbound calls made by /api/proxy and /api/chain
+type ProxyConfig struct {
+	AllowedHosts []string      `json:"allowedHosts,omitempty"` // host, host:port or *.domain
+	Timeout      time.Duration `json:"timeout"`
+	MaxBodyBytes int64         `json:"maxBodyBytes"`
+	MaxHops      int           `json:"maxHops"`
+}
+
+// TopologyConfig enables the multi-service simulator. Services without their own
+// port share Port and are selected by the Host header.
+type TopologyConfig struct {
+	File string `json:"file,omitempty"`
+	Port string `json:"port"`
+}
+
+var currentConfig atomic.Pointer[Config]
+
+// loadConfig builds the configuration from environment variables
+func loadConfig() *Config {
+	cfg := &Config{
+		Port:         getEnv("PORT", "8000"),
+		Environment:  getEnv("APP_ENV", "development"),
+		ServiceName:  getEnv("OTEL_SERVICE_NAME", "demo-app"),
+		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "jaeger:4318"),
+		LogLevel:     getEnv("LOG_LEVEL", "info"),
+		SampleRatio:  getEnvFloat("OTEL_TRACES_SAMPLER_ARG", 1.0),
+		Admin: AdminConfig{
+			Enabled:      getEnvBool("ADMIN_ENABLED", true),
+			Port:         getEnv("ADMIN_PORT", "9000"),
+			LoopbackOnly: getEnvBool("ADMIN_LOOPBACK_ONLY", true),
+			Token:        os.Getenv("ADMIN_TOKEN"),
+			TLSCertFile:  os.Getenv("ADMIN_TLS_CERT_FILE"),
+			TLSKeyFile:   os.Getenv("ADMIN_TLS_KEY_FILE"),
+			ClientCAFile: os.Getenv("ADMIN_CLIENT_CA_FILE"),
+			TraceStore:   getEnvInt("ADMIN_TRACE_STORE_SPANS", 2000),
+		},
+		Stress: StressConfig{
+			MaxCores:      getEnvInt("STRESS_MAX_CORES", runtime.NumCPU()),
+			MaxMemoryMB:   getEnvInt("STRESS_MAX_MEMORY_MB", 1024),
+			MaxGoroutines: getEnvInt("STRESS_MAX_GOROUTINES", 100000),
+			MaxDiskMB:     getEnvInt("STRESS_MAX_DISK_MB", 1024),
+			MaxDuration:   getEnvDuration("STRESS_MAX_DURATION", 10*time.Minute),
+		},
+		Concurrency: ConcurrencyConfig{
+			MaxInFlight:     getEnvInt("MAX_CONCURRENT_REQUESTS", 0),
+			MaxQueue:        getEnvInt("MAX_QUEUED_REQUESTS", 100),
+			QueueTimeout:    getEnvDuration("QUEUE_TIMEOUT", time.Second),
+			RetryAfter:      getEnvDuration("RETRY_AFTER", time.Second),
+			RoutePriorities: getEnvMap("ROUTE_PRIORITIES"),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:           getEnvBool("RATE_LIMIT_ENABLED", false),
+			KeyBy:             getEnv("RATE_LIMIT_KEY_BY", "ip"),
+			Header:            os.Getenv("RATE_LIMIT_HEADER"),
+			TrustForwardedFor: getEnvBool("RATE_LIMIT_TRUST_FORWARDED_FOR", false),
+			Default: RateLimitPolicy{
+				RequestsPerSecond: getEnvFloat("RATE_LIMIT_RPS", 10),
+				Burst:             getEnvInt("RATE_LIMIT_BURST", 20),
+			},
+		},
+		Auth: AuthConfig{
+			Enabled:     getEnvBool("AUTH_ENABLED", false),
+			APIKeysFile: os.Getenv("AUTH_API_KEYS_FILE"),
+			JWTSecret:   os.Getenv("AUTH_JWT_SECRET"),
+			JWKSFile:    os.Getenv("AUTH_JWKS_FILE"),
+			Issuer:      os.Getenv("AUTH_JWT_ISSUER"),
+			Audience:    os.Getenv("AUTH_JWT_AUDIENCE"),
+			ClockSkew:   getEnvDuration("AUTH_CLOCK_SKEW", 30*time.Second),
+			Default:     AuthRequirement{Required: getEnvBool("AUTH_REQUIRED", true)},
+		},
+		RBAC: RBACConfig{
+			Enabled: getEnvBool("RBAC_ENABLED", false),
+			Roles: map[string][]Permission{
+				"viewer":   {{Methods: []string{"GET", "HEAD"}, Path: "*"}},
+				"operator": {{Methods: []string{"*"}, Path: "/api/*"}, {Methods: []string{"GET", "HEAD"}, Path: "*"}},
+				"admin":    {{Methods: []string{"*"}, Path: "*"}},
+			},
+			Bindings:  getEnvBindings("RBAC_BINDINGS"),
+			AdminRole: getEnv("RBAC_ADMIN_ROLE", "admin"),
+		},
+		CORS: CORSConfig{
+			Enabled: getEnvBool("CORS_ENABLED", false),
+			Default: CORSPolicy{
+				AllowedOrigins:   getEnvList("CORS_ALLOWED_ORIGINS", nil),
+				AllowedMethods:   getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "OPTIONS"}),
+				AllowedHeaders:   getEnvList("CORS_ALLOWED_HEADERS", []string{"Content-Type", "Authorization", "X-API-Key"}),
+				ExposedHeaders:   getEnvList("CORS_EXPOSED_HEADERS", []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}),
+				AllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", false),
+				MaxAge:           getEnvInt("CORS_MAX_AGE", 600),
+			},
+		},
+		Compression: CompressionConfig{
+			Enabled:      getEnvBool("COMPRESSION_ENABLED", true),
+			MinSize:      getEnvInt("COMPRESSION_MIN_SIZE", 1024),
+			ContentTypes: getEnvList("COMPRESSION_TYPES", []string{"text/", "application/json", "application/problem+json", "application/javascript", "image/svg+xml"}),
+			Encodings:    getEnvList("COMPRESSION_ENCODINGS", []string{"zstd", "br", "gzip", "deflate"}),
+		},
+		Cache: CacheConfig{
+			Enabled: getEnvBool("CACHE_ENABLED", true),
+			Routes: map[string]CachePolicy{
+				"/version":    {CacheControl: fmt.Sprintf("public, max-age=%d", getEnvInt("CACHE_MAX_AGE", 60)), ETag: "strong", LastModified: true},
+				"/api/info":   {CacheControl: fmt.Sprintf("public, max-age=%d", getEnvInt("CACHE_MAX_AGE", 60)), ETag: "weak", LastModified: true},
+				"/api/time":   {CacheControl: "no-store"},
+				"/api/random": {CacheControl: "no-store"},
+			},
+		},
+		Security: SecurityHeadersConfig{
+			Enabled:               getEnvBool("SECURITY_HEADERS_ENABLED", true),
+			HSTSMaxAge:            getEnvInt("HSTS_MAX_AGE", 31536000),
+			HSTSIncludeSubdomains: getEnvBool("HSTS_INCLUDE_SUBDOMAINS", false),
+			ContentSecurityPolicy: getEnv("CSP", "default-src 'self'; style-src 'self' 'nonce-{nonce}'; script-src 'self' 'nonce-{nonce}'; "+
+				"img-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'none'; report-uri /csp-report"),
+			CSPReportOnly:     getEnvBool("CSP_REPORT_ONLY", false),
+			ReferrerPolicy:    getEnv("REFERRER_POLICY", "strict-origin-when-cross-origin"),
+			PermissionsPolicy: getEnv("PERMISSIONS_POLICY", "camera=(), microphone=(), geolocation=(), payment=()"),
+			FrameOptions:      getEnv("FRAME_OPTIONS", "DENY"),
+		},
+		H2C:      getEnvBool("H2C_ENABLED", true),
+		Socket:   getEnv("UNIX_SOCKET", ""),
+		Shutdown: getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+		WebSocket: WebSocketConfig{
+			MaxConnections:  getEnvInt("WS_MAX_CONNECTIONS", 1000),
+			IdleTimeout:     getEnvDuration("WS_IDLE_TIMEOUT", 60*time.Second),
+			PingInterval:    getEnvDuration("WS_PING_INTERVAL", 20*time.Second),
+			MaxMessageBytes: int64(getEnvInt("WS_MAX_MESSAGE_BYTES", 64<<10)),
+			AllowedOrigins:  getEnvList("WS_ALLOWED_ORIGINS", nil),
+		},
+		Events: EventsConfig{
+			BufferSize:      getEnvInt("EVENTS_BUFFER_SIZE", 256),
+			MetricsInterval: getEnvDuration("EVENTS_METRICS_INTERVAL", 5*time.Second),
+			Heartbeat:       getEnvDuration("EVENTS_HEARTBEAT", 15*time.Second),
+		},
+		Dashboard: DashboardConfig{
+			TraceURL: getEnv("DASHBOARD_TRACE_URL", "http://localhost:16686/trace/{traceId}"),
+		},
+		I18n: I18nConfig{
+			DefaultLocale: getEnv("DEFAULT_LOCALE", ""),
+		},
+		Proxy: ProxyConfig{
+			AllowedHosts: getEnvList("PROXY_ALLOWED_HOSTS", nil),
+			Timeout:      getEnvDuration("PROXY_TIMEOUT", 10*time.Second),
+			MaxBodyBytes: int64(getEnvInt("PROXY_MAX_BODY_BYTES", 1<<20)),
+			MaxHops:      getEnvInt("PROXY_MAX_HOPS", 5),
+		},
+		Topology: TopologyConfig{
+			File: os.Getenv("TOPOLOGY_FILE"),
+			Port: getEnv("TOPOLOGY_PORT", "8100"),
+		},
+		GRPC: GRPCConfig{
+			Enabled: getEnvBool("GRPC_ENABLED", true),
+			Port:    getEnv("GRPC_PORT", "9090"),
+		},
+		TLS: TLSConfig{
+			Enabled:        getEnvBool("TLS_ENABLED", false),
+			CertFile:       os.Getenv("TLS_CERT_FILE"),
+			KeyFile:        os.Getenv("TLS_KEY_FILE"),
+			SelfSigned:     getEnvBool("TLS_SELF_SIGNED", false),
+			ClientCAFile:   os.Getenv("TLS_CLIENT_CA_FILE"),
+			ClientAuth:     getEnv("TLS_CLIENT_AUTH", "none"),
+			ReloadInterval: getEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second),
+		},
+		File: os.Getenv("CONFIG_FILE"),
+	}
+	return cfg
+}
+
+// readConfig loads the environment configuration and overlays the JSON file
+// named by CONFIG_FILE, if any
+func readConfig() (*Config, error) {
+	cfg := loadConfig()
+	if cfg.File == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", cfg.File, err)
+	}
+	return cfg, nil
+}
+
+// applyConfig makes cfg current and applies the settings that can change at runtime.
+// Listener addresses, TLS and concurrency limits only take effect on restart.
+func applyConfig(cfg *Config) error {
+	authenticator, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		return err
+	}
+	if err := cfg.WebSocket.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Events.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.validate(); err != nil {
+		return err
+	}
+	if cfg.Shutdown <= 0 {
+		return fmt.Errorf("shutdown timeout must be positive, got %s", cfg.Shutdown)
+	}
+	if err := cfg.RBAC.validate(); err != nil {
+		return err
+	}
+	if err := validateSamplingRules(cfg.Sampling); err != nil {
+		return err
+	}
+	if cfg.Admin.TraceStore < 0 {
+		return fmt.Errorf("admin trace store size must not be negative, got %d", cfg.Admin.TraceStore)
+	}
+
+	if err := setLogLevel(cfg.LogLevel); err != nil {
+		log.Printf("[WARN] %v, using %s", err, currentLogLevel())
+	}
+	sampler.SetRatio(cfg.SampleRatio)
+	sampler.SetRules(cfg.Sampling)
+	traces.resize(cfg.Admin.TraceStore)
+	stress.setLimits(cfg.Stress)
+	rateLimits.setConfig(cfg.RateLimit)
+	auth.Store(authenticator)
+	rbac.Store(&cfg.RBAC)
+	corsConfig.Store(&cfg.CORS)
+	compressionConfig.Store(&cfg.Compression)
+	cacheConfig.Store(&cfg.Cache)
+	securityConfig.Store(&cfg.Security)
+	wsConfig.Store(&cfg.WebSocket)
+	eventsConfig.Store(&cfg.Events)
+	events.setBufferSize(cfg.Events.BufferSize)
+	dashboardConfig.Store(&cfg.Dashboard)
+	proxyConfig.Store(&cfg.Proxy)
+	if cfg.I18n.DefaultLocale == "" {
+		i18nConfig.Store(&cfg.I18n)
+	} else if tag := matchLocale(cfg.I18n.DefaultLocale); tag != "" {
+		cfg.I18n.DefaultLocale = tag
+		i18nConfig.Store(&cfg.I18n)
+	} else {
+		log.Printf("[WARN] No catalog for default locale %q, using %s", cfg.I18n.DefaultLocale, i18nConfig.Load().DefaultLocale)
+	}
+	currentConfig.Store(cfg)
+	return nil
+}
+
+// reloadConfig re-reads the configuration, keeping the current one on error
+func reloadConfig() error {
+	cfg, err := readConfig()
+	if err == nil {
+		err = applyConfig(cfg)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Config reload failed: %v", err)
+		publishLifecycle("config.reload_failed", err.Error())
+		setHealth("config", "fail", err.Error())
+		return err
+	}
+	log.Printf("[INFO] Config reloaded")
+	publishLifecycle("config.reloaded", cfg.File)
+	setHealth("config", "ok", "reloaded")
+	return nil
+}
+
+// redacted returns a copy of the config that is safe to expose
+func (c *Config) redacted() Config {
+	out := *c
+	if out.Admin.Token != "" {
+		out.Admin.Token = "***"
+	}
+	if out.Auth.JWTSecret != "" {
+		out.Auth.JWTSecret = "***"
+	}
+	return out
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvInt(key string, fallback int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// getEnvMap parses "key=value,key=value" lists
+func getEnvMap(key string) map[string]string {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	m := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(pair, "=")
+		if ok {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+	}
+	return m
+}
+
+// getEnvList parses comma-separated lists
+func getEnvList(key string, fallback []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvBindings parses "subject=role|role,subject=role" lists
+func getEnvBindings(key string) map[string][]string {
+	bindings := make(map[string][]string)
+	for subject, roles := range getEnvMap(key) {
+		bindings[subject] = strings.Split(roles, "|")
+	}
+	return bindings
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(key)), 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
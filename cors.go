@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var corsConfig atomic.Pointer[CORSConfig]
+
+func init() {
+	corsConfig.Store(&CORSConfig{})
+}
+
+// policyFor returns the CORS policy of a route, falling back to the default
+func (cfg *CORSConfig) policyFor(path string) CORSPolicy {
+	if p, ok := cfg.Routes[path]; ok {
+		return p
+	}
+	return cfg.Default
+}
+
+// allowOrigin returns the value for Access-Control-Allow-Origin, or "" if origin is not allowed.
+// Patterns are exact origins, "*", or wildcard subdomains such as "https://*.example.com".
+func (p CORSPolicy) allowOrigin(origin string) string {
+	for _, pattern := range p.AllowedOrigins {
+		switch {
+		case pattern == "*":
+			if p.AllowCredentials {
+				// Browsers reject "*" on credentialed requests, so reflect the origin
+				return origin
+			}
+			return "*"
+		case strings.EqualFold(pattern, origin):
+			return origin
+		case strings.Contains(pattern, "://*."):
+			scheme, suffix, _ := strings.Cut(pattern, "://*")
+			if strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(scheme)+3+len(suffix) {
+				return origin
+			}
+		}
+	}
+	return ""
+}
+
+// handleCORS answers preflight requests and adds Access-Control-* headers to actual requests
+func handleCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := corsConfig.Load()
+		origin := r.Header.Get("Origin")
+		if !cfg.Enabled || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		policy := cfg.policyFor(r.URL.Path)
+		allowed := policy.allowOrigin(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(
+			attribute.String("cors.origin", origin),
+			attribute.Bool("cors.allowed", allowed != ""),
+			attribute.Bool("cors.preflight", preflight),
+		)
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			method := r.Header.Get("Access-Control-Request-Method")
+			if allowed == "" || !containsFold(policy.AllowedMethods, method) {
+				span.AddEvent("cors.preflight.rejected", trace.WithAttributes(attribute.String("cors.request_method", method)))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			span.AddEvent("cors.preflight", trace.WithAttributes(attribute.String("cors.request_method", method)))
+			h.Set("Access-Control-Allow-Origin", allowed)
+			h.Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+			if len(policy.AllowedHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+			}
+			if policy.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if policy.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed != "" {
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if policy.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if len(policy.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func containsFold(list []string, v string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleCORS(t *testing.T) {
+	cfg := &CORSConfig{
+		Enabled: true,
+		Default: CORSPolicy{
+			AllowedOrigins: []string{"https://app.example.com", "https://*.internal.example.com"},
+			AllowedMethods: []string{"GET", "POST"},
+			AllowedHeaders: []string{"Content-Type"},
+			MaxAge:         600,
+		},
+		Routes: map[string]CORSPolicy{
+			"/api/echo": {
+				AllowedOrigins:   []string{"*"},
+				AllowedMethods:   []string{"GET", "POST", "PUT"},
+				AllowCredentials: true,
+			},
+		},
+	}
+	old := corsConfig.Load()
+	corsConfig.Store(cfg)
+	defer corsConfig.Store(old)
+
+	handler := handleCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		origin        string
+		requestMethod string
+		expected      int
+		allowOrigin   string
+	}{
+		{"simple allowed", "GET", "/api/hello", "https://app.example.com", "", http.StatusOK, "https://app.example.com"},
+		{"simple disallowed", "GET", "/api/hello", "https://evil.com", "", http.StatusOK, ""},
+		{"wildcard subdomain", "GET", "/api/hello", "https://a.b.internal.example.com", "", http.StatusOK, "https://a.b.internal.example.com"},
+		{"wildcard apex", "GET", "/api/hello", "https://internal.example.com", "", http.StatusOK, ""},
+		{"preflight allowed", "OPTIONS", "/api/hello", "https://app.example.com", "POST", http.StatusNoContent, "https://app.example.com"},
+		{"preflight bad method", "OPTIONS", "/api/hello", "https://app.example.com", "DELETE", http.StatusForbidden, ""},
+		{"preflight bad origin", "OPTIONS", "/api/hello", "https://evil.com", "GET", http.StatusForbidden, ""},
+		{"route credentials reflect", "OPTIONS", "/api/echo", "https://evil.com", "PUT", http.StatusNoContent, "https://evil.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("unexpected Access-Control-Allow-Origin: got %q want %q", got, tt.allowOrigin)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("OPTIONS", "/api/hello", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("unexpected Access-Control-Max-Age: got %q", got)
+	}
+	if got := rr.Header().Values("Vary"); len(got) == 0 || got[0] != "Origin" {
+		t.Errorf("unexpected Vary: %v", got)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The dashboard is compiled into the binary so it works without network access
+//
+//go:embed dashboard
+var dashboardFS embed.FS
+
+var (
+	dashboardTemplate = template.Must(template.ParseFS(dashboardFS, "dashboard/templates/index.html"))
+	dashboardConfig   atomic.Pointer[DashboardConfig]
+)
+
+func init() {
+	dashboardConfig.Store(&DashboardConfig{})
+}
+
+// HealthCheck is the last known state of one component, shown on the dashboard
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok, fail or disabled
+	Detail  string `json:"detail,omitempty"`
+	Updated string `json:"updated"`
+}
+
+var healthState sync.Map // name -> HealthCheck
+
+var healthWatchers = struct {
+	sync.Mutex
+	next int
+	fns  map[int]func(healthy bool)
+}{fns: make(map[int]func(bool))}
+
+// setHealth records the state of a component, publishes the full list as a health
+// event and tells the watchers whether the app as a whole is still healthy
+func setHealth(name, status, detail string) {
+	healthState.Store(name, HealthCheck{Name: name, Status: status, Detail: detail, Updated: time.Now().UTC().Format(time.RFC3339)})
+	events.publish("health", healthChecks())
+
+	healthWatchers.Lock()
+	defer healthWatchers.Unlock()
+	ok := healthy()
+	for _, fn := range healthWatchers.fns {
+		fn(ok)
+	}
+}
+
+// healthy reports whether no component has failed. Optional listeners that could
+// not start are "degraded" instead: the public port still serves, so they are shown
+// without failing readiness, which would otherwise stay unready until a restart.
+func healthy() bool {
+	for _, c := range healthChecks() {
+		if c.Status == "fail" {
+			return false
+		}
+	}
+	return true
+}
+
+// watchHealth calls fn with the current state and again on every change, until
+// the returned function is called
+func watchHealth(fn func(healthy bool)) (stop func()) {
+	healthWatchers.Lock()
+	defer healthWatchers.Unlock()
+	id := healthWatchers.next
+	healthWatchers.next++
+	healthWatchers.fns[id] = fn
+	fn(healthy())
+	return func() {
+		healthWatchers.Lock()
+		defer healthWatchers.Unlock()
+		delete(healthWatchers.fns, id)
+	}
+}
+
+func healthChecks() []HealthCheck {
+	var checks []HealthCheck
+	healthState.Range(func(_, v any) bool {
+		checks = append(checks, v.(HealthCheck))
+		return true
+	})
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+	return checks
+}
+
+type dashboardEndpoint struct {
+	Method string
+	Path   string // the description is the catalog entry "endpoint.<path>"
+}
+
+var dashboardEndpoints = []dashboardEndpoint{
+	{"GET", "/health"},
+	{"GET", "/ready"},
+	{"GET", "/version"},
+	{"GET", "/api/hello"},
+	{"GET", "/api/status"},
+	{"GET", "/api/feature"},
+	{"GET", "/api/metrics"},
+	{"GET", "/api/events"},
+	{"GET/POST", "/api/echo"},
+	{"GET", "/api/info"},
+	{"GET", "/api/time"},
+	{"GET", "/api/random"},
+	{"GET", "/api/proxy"},
+	{"GET", "/api/chain"},
+	{"WS", "/ws/echo"},
+	{"WS", "/ws/broadcast"},
+}
+
+// dashboardPage is the initial state rendered into the page; updates arrive over /api/events
+type dashboardPage struct {
+	VersionInfo
+	Printer
+	Locales     []string
+	Environment string
+	StartTime   string
+	Uptime      string
+	TraceURL    string
+	Health      []HealthCheck
+	Faults      []FaultRule
+	Endpoints   []dashboardEndpoint
+	Nonce       string
+}
+
+func rootHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if tracer != nil {
+		var span trace.Span
+		ctx, span = tracer.Start(ctx, "rootHandler")
+		defer span.End()
+		span.SetAttributes(attribute.String("handler", "root"))
+	}
+
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	atomic.AddInt64(&requestCount, 1)
+	log.Printf("[INFO] Root page accessed, request count: %d, traceId: %s", atomic.LoadInt64(&requestCount), getTraceID(ctx))
+
+	page := dashboardPage{
+		VersionInfo: VersionInfo{Version: Version, BuildTime: BuildTime, GitCommit: GitCommit},
+		Printer:     printerFromContext(ctx),
+		Locales:     supportedLocales,
+		Environment: newStatusResponse(ctx).Environment,
+		StartTime:   startTime.UTC().Format(time.RFC3339),
+		Uptime:      time.Since(startTime).Round(time.Second).String(),
+		TraceURL:    dashboardConfig.Load().TraceURL,
+		Health:      healthChecks(),
+		Faults:      faults.Active(),
+		Endpoints:   dashboardEndpoints,
+		Nonce:       cspNonce(ctx),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		log.Printf("[ERROR] Failed to render dashboard: %v, traceId: %s", err, getTraceID(ctx))
+	}
+}
+
+// dashboardAssets serves the embedded scripts under /static/, without directory listings
+func dashboardAssets() http.Handler {
+	assets, err := fs.Sub(dashboardFS, "dashboard/static")
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix("/static/", http.FileServer(http.FS(assets)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,194 @@
+// Live dashboard: all state comes from the /api/events stream, nothing is loaded from other origins.
+(function () {
+    'use strict';
+
+    var WINDOW_MS = 60 * 1000;
+    var MAX_ERRORS = 20;
+    var MAX_LIFECYCLE = 20;
+
+    var body = document.body;
+    var startTime = Date.parse(body.dataset.startTime);
+    var traceURL = body.dataset.traceLink;
+
+    var requests = []; // {time, route, failed, latencyMs}
+    var errors = [];
+    var lifecycle = [];
+
+    function $(id) { return document.getElementById(id); }
+
+    function cell(row, text, className) {
+        var td = row.insertCell();
+        td.textContent = text;
+        if (className) td.className = className;
+        return td;
+    }
+
+    function replaceRows(tbody, items, columns, render) {
+        tbody.textContent = '';
+        if (items.length === 0) {
+            var row = tbody.insertRow();
+            var td = cell(row, tbody.dataset.empty, 'empty');
+            td.colSpan = columns;
+            return;
+        }
+        items.forEach(function (item) { render(tbody.insertRow(), item); });
+    }
+
+    function clock(ts) {
+        var d = new Date(ts);
+        return isNaN(d) ? ts : d.toLocaleTimeString();
+    }
+
+    function formatDuration(ms) {
+        var s = Math.floor(ms / 1000);
+        var h = Math.floor(s / 3600), m = Math.floor(s % 3600 / 60);
+        return (h ? h + 'h' : '') + (h || m ? m + 'm' : '') + (s % 60) + 's';
+    }
+
+    function traceLink(row, traceId) {
+        var td = row.insertCell();
+        if (!traceId) return;
+        if (!traceURL) {
+            td.textContent = traceId.slice(0, 8);
+            return;
+        }
+        var a = document.createElement('a');
+        a.href = traceURL.replace('{traceId}', encodeURIComponent(traceId));
+        a.target = '_blank';
+        a.rel = 'noopener';
+        a.textContent = traceId.slice(0, 8);
+        td.appendChild(a);
+    }
+
+    function renderRoutes() {
+        var cutoff = Date.now() - WINDOW_MS;
+        while (requests.length && requests[0].time < cutoff) requests.shift();
+
+        var byRoute = {};
+        requests.forEach(function (r) {
+            var s = byRoute[r.route] || (byRoute[r.route] = {route: r.route, count: 0, latency: 0, errors: 0});
+            s.count++;
+            s.latency += r.latencyMs;
+            if (r.failed) s.errors++;
+        });
+        var routes = Object.keys(byRoute).map(function (k) { return byRoute[k]; });
+        routes.sort(function (a, b) { return b.count - a.count || a.route.localeCompare(b.route); });
+
+        $('request-rate').textContent = (requests.length / (WINDOW_MS / 1000)).toFixed(2);
+        replaceRows($('routes'), routes, 4, function (row, s) {
+            cell(row, s.route);
+            cell(row, (s.count / (WINDOW_MS / 1000)).toFixed(2), 'num');
+            cell(row, (s.latency / s.count).toFixed(1) + ' ms', 'num');
+            cell(row, String(s.errors), s.errors ? 'num fail' : 'num');
+        });
+    }
+
+    function renderErrors() {
+        replaceRows($('errors'), errors, 4, function (row, e) {
+            cell(row, clock(e.timestamp));
+            cell(row, e.method + ' ' + e.route);
+            cell(row, e.error ? e.status + ' · ' + e.error : String(e.status), 'fail');
+            traceLink(row, e.traceId);
+        });
+    }
+
+    function renderLifecycle() {
+        replaceRows($('lifecycle'), lifecycle, 3, function (row, e) {
+            cell(row, clock(e.timestamp));
+            cell(row, e.event, /failed$/.test(e.event) ? 'fail' : '');
+            cell(row, e.detail || '');
+        });
+    }
+
+    // Rules expire on the server without an event, so rows past their expiry are dropped here
+    function renderFaults(rules) {
+        replaceRows($('faults'), rules, 3, function (row, f) {
+            row.dataset.expires = f.expiresAt;
+            cell(row, f.route);
+            var fault = [];
+            if (f.latency) fault.push('+' + f.latency);
+            if (f.errorRate) fault.push(f.errorRate + ' × ' + f.status);
+            cell(row, fault.join(' · '), 'fail');
+            cell(row, clock(f.expiresAt));
+        });
+    }
+
+    function pruneFaults() {
+        var tbody = $('faults');
+        Array.prototype.slice.call(tbody.rows).forEach(function (row) {
+            if (row.dataset.expires && Date.parse(row.dataset.expires) <= Date.now()) row.remove();
+        });
+        if (tbody.rows.length === 0) replaceRows(tbody, [], 3);
+    }
+
+    function renderHealth(checks) {
+        replaceRows($('health'), checks, 3, function (row, c) {
+            cell(row, c.name);
+            cell(row, c.status, c.status);
+            cell(row, c.detail || '');
+        });
+    }
+
+    // Server errors, and errors the handler reported without failing the response
+    function onRequest(r) {
+        var time = Date.parse(r.timestamp);
+        var failed = r.status >= 500 || !!r.error;
+        if (time >= Date.now() - WINDOW_MS) {
+            requests.push({time: time, route: r.route, failed: failed, latencyMs: r.latencyMs});
+        }
+        if (failed) {
+            errors.unshift(r);
+            errors.length = Math.min(errors.length, MAX_ERRORS);
+            renderErrors();
+        }
+    }
+
+    function onMetrics(m) {
+        $('request-count').textContent = m.requestCount;
+        $('goroutines').textContent = m.goRoutines;
+        $('memory').textContent = m.memoryUsage;
+        $('websockets').textContent = m.webSockets ? m.webSockets.active : 0;
+    }
+
+    function onLifecycle(e) {
+        lifecycle.unshift(e);
+        lifecycle.length = Math.min(lifecycle.length, MAX_LIFECYCLE);
+        renderLifecycle();
+    }
+
+    function parse(handler) {
+        return function (msg) { handler(JSON.parse(msg.data)); };
+    }
+
+    // EventSource cannot send headers: with authentication enabled, open the page as
+    // /#credential=<API key or JWT> and the stream gets it as a cookie instead
+    var credential = /^#credential=(.+)$/.exec(location.hash);
+    if (credential) {
+        document.cookie = 'demo_app_credential=' + encodeURIComponent(decodeURIComponent(credential[1])) +
+            '; path=/api/events; SameSite=Strict' + (location.protocol === 'https:' ? '; Secure' : '');
+        history.replaceState(null, '', location.pathname + location.search);
+    }
+
+    // lastEventId=0 replays the server's buffer so the page starts with recent history;
+    // on reconnect the browser sends Last-Event-ID, which takes precedence
+    var source = new EventSource('/api/events?types=metrics,request,lifecycle,health,faults&lastEventId=0');
+    source.addEventListener('open', function () {
+        $('stream').textContent = body.dataset.streamOpen;
+        $('stream').className = 'stream open';
+    });
+    source.addEventListener('error', function () {
+        $('stream').textContent = body.dataset.streamError;
+        $('stream').className = 'stream error';
+    });
+    source.addEventListener('metrics', parse(onMetrics));
+    source.addEventListener('request', parse(onRequest));
+    source.addEventListener('lifecycle', parse(onLifecycle));
+    source.addEventListener('health', parse(renderHealth));
+    source.addEventListener('faults', parse(renderFaults));
+
+    setInterval(function () {
+        if (!isNaN(startTime)) $('uptime').textContent = formatDuration(Date.now() - startTime);
+        renderRoutes();
+        pruneFaults();
+    }, 1000);
+})();
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRootDashboard(t *testing.T) {
+	cfg := loadConfig().Dashboard
+	old := dashboardConfig.Load()
+	dashboardConfig.Store(&cfg)
+	defer dashboardConfig.Store(old)
+	setHealth("grpc", "fail", "listen tcp :9090: bind: address already in use")
+	defer healthState.Delete("grpc")
+	defer faults.Remove(0)
+	if _, err := faults.Add(FaultRule{Route: "/api/slow-test", Latency: "200ms"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	rootHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"<title>Demo App v" + Version + "</title>",
+		`data-trace-link="http://localhost:16686/trace/{traceId}"`,
+		`<script src="/static/dashboard.js"`,
+		`<td class="fail">fail</td>`,
+		"<code>/api/events</code>",
+		`<tbody id="faults"`,
+		"<td>/api/slow-test</td>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("dashboard is missing %q", want)
+		}
+	}
+	if strings.Contains(body, "v2.5 开发版") || strings.Contains(body, "://cdn") {
+		t.Error("dashboard still has a hard-coded version or loads from a CDN")
+	}
+
+	rr = httptest.NewRecorder()
+	rootHandler(rr, httptest.NewRequest("GET", "/missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unexpected status for unknown path: %d", rr.Code)
+	}
+}
+
+func TestDashboardAssets(t *testing.T) {
+	handler := dashboardAssets()
+	tests := []struct {
+		path   string
+		status int
+		ctype  string
+	}{
+		{"/static/dashboard.js", http.StatusOK, "text/javascript"},
+		{"/static/", http.StatusNotFound, ""},
+		{"/static/missing.js", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+			if rr.Code != tt.status {
+				t.Errorf("unexpected status: got %d want %d", rr.Code, tt.status)
+			}
+			if tt.ctype != "" && !strings.HasPrefix(rr.Header().Get("Content-Type"), tt.ctype) {
+				t.Errorf("unexpected Content-Type: %q", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestSetHealthPublishes(t *testing.T) {
+	ch, _, _ := events.subscribe(0, false)
+	defer events.unsubscribe(ch)
+	defer healthState.Delete("zz-test")
+
+	setHealth("zz-test", "ok", "ready")
+	e := <-ch
+	if e.Type != "health" {
+		t.Fatalf("unexpected event type: %q", e.Type)
+	}
+	var checks []HealthCheck
+	if err := json.Unmarshal(e.Data, &checks); err != nil {
+		t.Fatal(err)
+	}
+	if last := checks[len(checks)-1]; last.Name != "zz-test" || last.Status != "ok" || last.Detail != "ready" {
+		t.Errorf("unexpected health checks: %+v", checks)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxTraceDuration = 60 * time.Second
+	maxHeapSnapshots = 16
+)
+
+// registerDebugHandlers adds profiling and runtime inspection endpoints to the admin mux
+func registerDebugHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/admin/trace", traceCaptureHandler)
+	mux.HandleFunc("/admin/goroutines", goroutineDumpHandler)
+	mux.HandleFunc("/admin/heap/snapshots", heapSnapshotsHandler)
+	mux.HandleFunc("/admin/heap/diff", heapDiffHandler)
+}
+
+// traceCaptureHandler records an execution trace for ?duration= (default 5s)
+// and returns it for use with `go tool trace`
+func traceCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	duration := 5 * time.Second
+	if d := r.URL.Query().Get("duration"); d != "" {
+		parsed, err := time.ParseDuration(d)
+		if err != nil || parsed <= 0 {
+			writeAdminError(w, http.StatusBadRequest, "invalid duration: "+d)
+			return
+		}
+		duration = parsed
+	}
+	if duration > maxTraceDuration {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("duration exceeds maximum of %s", maxTraceDuration))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		writeAdminError(w, http.StatusConflict, "could not start trace: "+err.Error())
+		return
+	}
+	log.Printf("[INFO] Runtime trace capture started, duration: %s", duration)
+	select {
+	case <-time.After(duration):
+	case <-r.Context().Done():
+	}
+	trace.Stop()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace.out"`)
+	w.Write(buf.Bytes())
+}
+
+// goroutineDumpHandler writes every goroutine stack in panic-style text form
+func goroutineDumpHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "goroutines: %d\n\n", runtime.NumGoroutine())
+	rpprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// dumpGoroutines writes all goroutine stacks to the log
+func dumpGoroutines() {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	log.Printf("Goroutine dump (%d goroutines):\n%s", runtime.NumGoroutine(), buf)
+}
+
+type HeapSnapshot struct {
+	ID          int              `json:"id"`
+	Timestamp   string           `json:"timestamp"`
+	HeapAlloc   uint64           `json:"heapAlloc"`
+	HeapInuse   uint64           `json:"heapInuse"`
+	HeapObjects uint64           `json:"heapObjects"`
+	TotalAlloc  uint64           `json:"totalAlloc"`
+	NumGC       uint32           `json:"numGC"`
+	GoRoutines  int              `json:"goRoutines"`
+	sites       map[string]int64 // in-use bytes per allocation site
+}
+
+type HeapSiteDiff struct {
+	Site       string `json:"site"`
+	DeltaBytes int64  `json:"deltaBytes"`
+}
+
+type HeapDiffResponse struct {
+	From             int            `json:"from"`
+	To               int            `json:"to"`
+	Elapsed          string         `json:"elapsed"`
+	HeapAllocDelta   int64          `json:"heapAllocDelta"`
+	HeapInuseDelta   int64          `json:"heapInuseDelta"`
+	HeapObjectsDelta int64          `json:"heapObjectsDelta"`
+	AllocatedBetween uint64         `json:"allocatedBetween"`
+	GCsBetween       uint32         `json:"gcsBetween"`
+	GoRoutinesDelta  int            `json:"goRoutinesDelta"`
+	TopSites         []HeapSiteDiff `json:"topSites"`
+}
+
+var heapSnapshots = struct {
+	sync.Mutex
+	nextID int
+	list   []*HeapSnapshot
+}{nextID: 1}
+
+// takeHeapSnapshot records memory statistics and in-use bytes per allocation site
+func takeHeapSnapshot() *HeapSnapshot {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	snap := &HeapSnapshot{
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		HeapAlloc:   m.HeapAlloc,
+		HeapInuse:   m.HeapInuse,
+		HeapObjects: m.HeapObjects,
+		TotalAlloc:  m.TotalAlloc,
+		NumGC:       m.NumGC,
+		GoRoutines:  runtime.NumGoroutine(),
+		sites:       heapSites(),
+	}
+
+	heapSnapshots.Lock()
+	defer heapSnapshots.Unlock()
+	snap.ID = heapSnapshots.nextID
+	heapSnapshots.nextID++
+	heapSnapshots.list = append(heapSnapshots.list, snap)
+	if len(heapSnapshots.list) > maxHeapSnapshots {
+		heapSnapshots.list = heapSnapshots.list[1:]
+	}
+	return snap
+}
+
+func heapSites() map[string]int64 {
+	var records []runtime.MemProfileRecord
+	n, _ := runtime.MemProfile(nil, true)
+	for {
+		records = make([]runtime.MemProfileRecord, n+50)
+		var ok bool
+		n, ok = runtime.MemProfile(records, true)
+		if ok {
+			records = records[:n]
+			break
+		}
+	}
+
+	sites := make(map[string]int64)
+	for _, rec := range records {
+		site := "unknown"
+		frames := runtime.CallersFrames(rec.Stack())
+		for {
+			frame, more := frames.Next()
+			if frame.Function != "" {
+				site = frame.Function
+				break
+			}
+			if !more {
+				break
+			}
+		}
+		sites[site] += rec.InUseBytes()
+	}
+	return sites
+}
+
+func findHeapSnapshot(id int) *HeapSnapshot {
+	heapSnapshots.Lock()
+	defer heapSnapshots.Unlock()
+	for _, s := range heapSnapshots.list {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// heapSnapshotsHandler lists snapshots (GET) or takes a new one (POST)
+func heapSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		heapSnapshots.Lock()
+		list := append([]*HeapSnapshot(nil), heapSnapshots.list...)
+		heapSnapshots.Unlock()
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		snap := takeHeapSnapshot()
+		log.Printf("[INFO] Heap snapshot %d taken, heapAlloc: %.2f MB", snap.ID, float64(snap.HeapAlloc)/1024/1024)
+		writeJSON(w, http.StatusCreated, snap)
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// heapDiffHandler compares snapshot ?from= with snapshot ?to=, or with the
+// current heap when to is omitted
+func heapDiffHandler(w http.ResponseWriter, r *http.Request) {
+	fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, "from must be a snapshot id")
+		return
+	}
+	from := findHeapSnapshot(fromID)
+	if from == nil {
+		writeAdminError(w, http.StatusNotFound, fmt.Sprintf("snapshot %d not found", fromID))
+		return
+	}
+
+	var to *HeapSnapshot
+	if v := r.URL.Query().Get("to"); v != "" {
+		toID, err := strconv.Atoi(v)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, "to must be a snapshot id")
+			return
+		}
+		if to = findHeapSnapshot(toID); to == nil {
+			writeAdminError(w, http.StatusNotFound, fmt.Sprintf("snapshot %d not found", toID))
+			return
+		}
+	} else {
+		to = takeHeapSnapshot()
+	}
+
+	writeJSON(w, http.StatusOK, diffHeapSnapshots(from, to, 20))
+}
+
+func diffHeapSnapshots(from, to *HeapSnapshot, top int) HeapDiffResponse {
+	fromTime, _ := time.Parse(time.RFC3339Nano, from.Timestamp)
+	toTime, _ := time.Parse(time.RFC3339Nano, to.Timestamp)
+
+	var sites []HeapSiteDiff
+	for site, bytes := range to.sites {
+		if d := bytes - from.sites[site]; d != 0 {
+			sites = append(sites, HeapSiteDiff{Site: site, DeltaBytes: d})
+		}
+	}
+	for site, bytes := range from.sites {
+		if _, ok := to.sites[site]; !ok && bytes != 0 {
+			sites = append(sites, HeapSiteDiff{Site: site, DeltaBytes: -bytes})
+		}
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return abs64(sites[i].DeltaBytes) > abs64(sites[j].DeltaBytes)
+	})
+	if len(sites) > top {
+		sites = sites[:top]
+	}
+
+	return HeapDiffResponse{
+		From:             from.ID,
+		To:               to.ID,
+		Elapsed:          toTime.Sub(fromTime).Round(time.Millisecond).String(),
+		HeapAllocDelta:   int64(to.HeapAlloc) - int64(from.HeapAlloc),
+		HeapInuseDelta:   int64(to.HeapInuse) - int64(from.HeapInuse),
+		HeapObjectsDelta: int64(to.HeapObjects) - int64(from.HeapObjects),
+		AllocatedBetween: to.TotalAlloc - from.TotalAlloc,
+		GCsBetween:       to.NumGC - from.NumGC,
+		GoRoutinesDelta:  to.GoRoutines - from.GoRoutines,
+		TopSites:         sites,
+	}
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceCaptureHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{"short capture", "?duration=10ms", http.StatusOK},
+		{"invalid duration", "?duration=abc", http.StatusBadRequest},
+		{"too long", "?duration=1h", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/admin/trace"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(traceCaptureHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGoroutineDumpHandler(t *testing.T) {
+	req, err := http.NewRequest("GET", "/admin/goroutines", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(goroutineDumpHandler).ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "goroutine ") {
+		t.Errorf("dump does not contain goroutine stacks: %q", rr.Body.String())
+	}
+}
+
+func TestHeapDiff(t *testing.T) {
+	rr := httptest.NewRecorder()
+	heapSnapshotsHandler(rr, httptest.NewRequest("POST", "/admin/heap/snapshots", nil))
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+
+	var snap HeapSnapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	heapDiffHandler(rr, httptest.NewRequest("GET", fmt.Sprintf("/admin/heap/diff?from=%d", snap.ID), nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var diff HeapDiffResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &diff); err != nil {
+		t.Errorf("failed to parse response: %v", err)
+	}
+	if diff.From != snap.ID || diff.To <= snap.ID {
+		t.Errorf("unexpected snapshot ids in diff: from %d to %d", diff.From, diff.To)
+	}
+
+	rr = httptest.NewRecorder()
+	heapDiffHandler(rr, httptest.NewRequest("GET", "/admin/heap/diff?from=99999", nil))
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
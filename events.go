@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var eventsConfig atomic.Pointer[EventsConfig]
+
+func init() {
+	eventsConfig.Store(&EventsConfig{BufferSize: 256, MetricsInterval: 5 * time.Second, Heartbeat: 15 * time.Second})
+}
+
+// Event is one entry of the server-sent event stream
+type Event struct {
+	ID   uint64
+	Type string // metrics, request or lifecycle
+	Data json.RawMessage
+}
+
+// RequestEvent describes a completed request on the public listener
+type RequestEvent struct {
+	Method    string  `json:"method"`
+	Route     string  `json:"route"`
+	Status    int     `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Bytes     int64   `json:"bytes"`
+	Error     string  `json:"error,omitempty"` // set by markRequestError, whatever the status
+	TraceID   string  `json:"traceId,omitempty"`
+	Timestamp string  `json:"timestamp"`
+}
+
+// LifecycleEvent reports process-level changes such as startup and config reloads
+type LifecycleEvent struct {
+	Event     string `json:"event"`
+	Detail    string `json:"detail,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// eventBroker keeps a bounded history for Last-Event-ID resume and fans events out to subscribers
+type eventBroker struct {
+	mu     sync.Mutex
+	nextID uint64
+	buf    []Event
+	size   int
+	subs   map[chan Event]struct{}
+
+	draining atomic.Bool
+}
+
+var events = &eventBroker{size: 256, subs: make(map[chan Event]struct{})}
+
+// validate rejects settings that would panic the broker or the heartbeat ticker,
+// or make publishMetrics spin. A zero buffer disables Last-Event-ID resume.
+func (cfg *EventsConfig) validate() error {
+	if cfg.BufferSize < 0 {
+		return fmt.Errorf("events: buffer size %d must not be negative", cfg.BufferSize)
+	}
+	if cfg.MetricsInterval <= 0 || cfg.Heartbeat <= 0 {
+		return fmt.Errorf("events: metrics interval (%s) and heartbeat (%s) must be positive", cfg.MetricsInterval, cfg.Heartbeat)
+	}
+	return nil
+}
+
+func (b *eventBroker) setBufferSize(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.size = n
+	if len(b.buf) > n {
+		b.buf = append([]Event(nil), b.buf[len(b.buf)-n:]...)
+	}
+}
+
+// publish records the event and delivers it to every subscriber. A subscriber
+// that cannot keep up is disconnected so that it resumes from the buffer.
+func (b *eventBroker) publish(typ string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	e := Event{ID: b.nextID, Type: typ, Data: data}
+	b.buf = append(b.buf, e)
+	if len(b.buf) > b.size {
+		b.buf = b.buf[len(b.buf)-b.size:]
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the buffered events after lastID and a channel for new ones.
+// missed reports that events after lastID have already been evicted.
+func (b *eventBroker) subscribe(lastID uint64, resume bool) (ch chan Event, backlog []Event, missed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if resume {
+		for _, e := range b.buf {
+			if e.ID > lastID {
+				backlog = append(backlog, e)
+			}
+		}
+		missed = lastID < b.nextID && (len(backlog) == 0 || backlog[0].ID > lastID+1)
+	}
+	ch = make(chan Event, 64)
+	b.subs[ch] = struct{}{}
+	return ch, backlog, missed
+}
+
+func (b *eventBroker) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// disconnectAll ends every stream when the server drains. Events already queued
+// are still delivered, and clients resume elsewhere with Last-Event-ID.
+func (b *eventBroker) disconnectAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.draining.Store(true)
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+func (b *eventBroker) subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// publishMetrics emits a metrics snapshot every interval while anyone is listening
+func (b *eventBroker) publishMetrics() {
+	for {
+		time.Sleep(eventsConfig.Load().MetricsInterval)
+		if b.subscribers() > 0 {
+			b.publish("metrics", collectMetrics())
+		}
+	}
+}
+
+func publishLifecycle(event, detail string) {
+	events.publish("lifecycle", LifecycleEvent{Event: event, Detail: detail, Timestamp: time.Now().UTC().Format(time.RFC3339)})
+}
+
+type requestErrorKey struct{}
+
+// markRequestError flags the request behind ctx as failed in its request event,
+// for errors such as the simulated ones that do not change the response status
+func markRequestError(ctx context.Context, err error) {
+	if failed, ok := ctx.Value(requestErrorKey{}).(*atomic.Value); ok {
+		failed.Store(err.Error())
+	}
+}
+
+// publishRequests emits a request event for every completed request except the event stream itself
+func publishRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/events" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var failed atomic.Value
+		r = r.WithContext(context.WithValue(r.Context(), requestErrorKey{}, &failed))
+		m := httpsnoop.CaptureMetrics(next, w, r)
+		msg, _ := failed.Load().(string)
+		events.publish("request", RequestEvent{
+			Method:    r.Method,
+			Route:     r.URL.Path,
+			Status:    m.Code,
+			LatencyMs: float64(m.Duration.Microseconds()) / 1000,
+			Bytes:     m.Written,
+			Error:     msg,
+			TraceID:   getTraceID(r.Context()),
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		})
+	})
+}
+
+func writeEvent(w http.ResponseWriter, e Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
+
+// eventsHandler streams events as text/event-stream. Clients may filter with
+// ?types=metrics,request and resume with Last-Event-ID (or ?lastEventId=).
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeProblem(w, r, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	types := make(map[string]bool)
+	for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	lastID, err := strconv.ParseUint(lastEventID, 10, 64)
+	resume := err == nil
+
+	ch, backlog, missed := events.subscribe(lastID, resume)
+	defer events.unsubscribe(ch)
+
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(
+		attribute.Bool("sse.resumed", resume),
+		attribute.Int("sse.backlog", len(backlog)),
+		attribute.Bool("sse.missed", missed),
+	)
+	log.Printf("[INFO] Event stream opened, resumeFrom: %s, backlog: %d, traceId: %s", lastEventID, len(backlog), getTraceID(r.Context()))
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if missed {
+		// Some events were evicted; tell the client so it can reload full state
+		fmt.Fprint(w, "event: reset\ndata: {\"reason\":\"events after the requested id are no longer buffered\"}\n\n")
+	}
+	for _, e := range backlog {
+		if len(types) == 0 || types[e.Type] {
+			writeEvent(w, e)
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsConfig.Load().Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok && events.draining.Load() {
+				log.Printf("[INFO] Event stream closed for shutdown, traceId: %s", getTraceID(r.Context()))
+				return
+			}
+			if !ok {
+				span.AddEvent("sse.lagging")
+				log.Printf("[WARN] Event stream too slow, disconnecting, traceId: %s", getTraceID(r.Context()))
+				return
+			}
+			if len(types) == 0 || types[e.Type] {
+				writeEvent(w, e)
+				flusher.Flush()
+			}
+		case t := <-heartbeat.C:
+			fmt.Fprintf(w, ": heartbeat %s\n\n", t.UTC().Format(time.RFC3339))
+			flusher.Flush()
+		case <-r.Context().Done():
+			log.Printf("[INFO] Event stream closed, traceId: %s", getTraceID(r.Context()))
+			return
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBrokerResume(t *testing.T) {
+	b := &eventBroker{size: 3, subs: make(map[chan Event]struct{})}
+	for i := 0; i < 5; i++ {
+		b.publish("request", RequestEvent{Status: 200 + i})
+	}
+
+	tests := []struct {
+		name    string
+		lastID  uint64
+		resume  bool
+		backlog []uint64
+		missed  bool
+	}{
+		{"no resume", 0, false, nil, false},
+		{"within buffer", 3, true, []uint64{4, 5}, false},
+		{"oldest buffered", 2, true, []uint64{3, 4, 5}, false},
+		{"evicted", 1, true, []uint64{3, 4, 5}, true},
+		{"up to date", 5, true, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch, backlog, missed := b.subscribe(tt.lastID, tt.resume)
+			defer b.unsubscribe(ch)
+			var ids []uint64
+			for _, e := range backlog {
+				ids = append(ids, e.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.backlog) {
+				t.Errorf("unexpected backlog: got %v want %v", ids, tt.backlog)
+			}
+			if missed != tt.missed {
+				t.Errorf("unexpected missed: got %v want %v", missed, tt.missed)
+			}
+		})
+	}
+
+	// A subscriber that stops reading is disconnected rather than blocking publishers
+	ch, _, _ := b.subscribe(0, false)
+	for i := 0; i < cap(ch)+1; i++ {
+		b.publish("request", RequestEvent{})
+	}
+	for range ch {
+	}
+	if b.subscribers() != 0 {
+		t.Errorf("lagging subscriber was not removed")
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	old := eventsConfig.Load()
+	eventsConfig.Store(&EventsConfig{BufferSize: 256, MetricsInterval: time.Hour, Heartbeat: 50 * time.Millisecond})
+	defer eventsConfig.Store(old)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/events", eventsHandler)
+	mux.HandleFunc("/api/hello", helloHandler)
+	mux.HandleFunc("/api/flaky", func(w http.ResponseWriter, r *http.Request) {
+		markRequestError(r.Context(), errors.New("simulated"))
+	})
+	srv := httptest.NewServer(publishRequests(mux))
+	defer srv.Close()
+
+	publishLifecycle("config.reloaded", "test")
+	events.mu.Lock()
+	lastID := events.nextID
+	events.mu.Unlock()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/api/events?types=request,lifecycle", nil)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(lastID-1, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	expect := func(prefix string) string {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("stream ended while waiting for %q", prefix)
+				}
+				if strings.HasPrefix(line, prefix) {
+					return line
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %q", prefix)
+			}
+		}
+	}
+
+	// The resumed backlog replays the lifecycle event
+	if got := expect("id: "); got != "id: "+strconv.FormatUint(lastID, 10) {
+		t.Errorf("unexpected first replayed event: %q", got)
+	}
+	if got := expect("data: "); !strings.Contains(got, `"event":"config.reloaded"`) {
+		t.Errorf("unexpected lifecycle data: %q", got)
+	}
+
+	http.Get(srv.URL + "/api/hello")
+	expect("event: request")
+	if got := expect("data: "); !strings.Contains(got, `"route":"/api/hello"`) || !strings.Contains(got, `"status":200`) || strings.Contains(got, `"error"`) {
+		t.Errorf("unexpected request event: %q", got)
+	}
+	http.Get(srv.URL + "/api/flaky")
+	expect("event: request")
+	if got := expect("data: "); !strings.Contains(got, `"status":200`) || !strings.Contains(got, `"error":"simulated"`) {
+		t.Errorf("reported error missing from request event: %q", got)
+	}
+	expect(": heartbeat")
+}
+
+func TestEventsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   func(*EventsConfig)
+		valid bool
+	}{
+		{"defaults", func(*EventsConfig) {}, true},
+		{"no resume buffer", func(c *EventsConfig) { c.BufferSize = 0 }, true},
+		{"negative buffer", func(c *EventsConfig) { c.BufferSize = -1 }, false},
+		{"zero heartbeat", func(c *EventsConfig) { c.Heartbeat = 0 }, false},
+		{"zero metrics interval", func(c *EventsConfig) { c.MetricsInterval = 0 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			tt.cfg(&cfg.Events)
+			if err := cfg.Events.validate(); (err == nil) != tt.valid {
+				t.Errorf("validate() = %v, want valid %t", err, tt.valid)
+			}
+			if err := applyConfig(cfg); (err == nil) != tt.valid {
+				t.Errorf("applyConfig() = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+	applyConfig(loadConfig())
+}
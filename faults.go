@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FaultRule delays and/or fails public requests on Route until it expires.
+// A Route ending in "*" matches by prefix, a lone "*" matches everything.
+type FaultRule struct {
+	ID        int     `json:"id"`
+	Route     string  `json:"route"`
+	Latency   string  `json:"latency,omitempty"` // added before the handler runs
+	ErrorRate float64 `json:"errorRate"`         // fraction of matching requests that fail
+	Status    int     `json:"status,omitempty"`  // returned for failed requests, 500 by default
+	Duration  string  `json:"duration"`
+	ExpiresAt string  `json:"expiresAt"`
+
+	latency time.Duration
+	expires time.Time
+}
+
+func (f *FaultRule) matches(path string) bool {
+	if prefix, ok := strings.CutSuffix(f.Route, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return f.Route == path
+}
+
+type faultRegistry struct {
+	mu     sync.Mutex
+	nextID int
+	rules  []*FaultRule
+}
+
+var faults = &faultRegistry{nextID: 1}
+
+// Add validates the rule and activates it for duration
+func (f *faultRegistry) Add(rule FaultRule, duration time.Duration) (FaultRule, error) {
+	if rule.Route == "" || (rule.Route[0] != '/' && rule.Route != "*") {
+		return FaultRule{}, fmt.Errorf("route must be a path or \"*\", got %q", rule.Route)
+	}
+	if rule.ErrorRate < 0 || rule.ErrorRate > 1 {
+		return FaultRule{}, fmt.Errorf("errorRate must be between 0 and 1")
+	}
+	if rule.Status == 0 {
+		rule.Status = http.StatusInternalServerError
+	}
+	if rule.Status < 400 || rule.Status > 599 {
+		return FaultRule{}, fmt.Errorf("status must be a 4xx or 5xx code")
+	}
+	if rule.Latency != "" {
+		var err error
+		if rule.latency, err = time.ParseDuration(rule.Latency); err != nil || rule.latency < 0 {
+			return FaultRule{}, fmt.Errorf("invalid latency: %s", rule.Latency)
+		}
+	}
+	if rule.latency == 0 && rule.ErrorRate == 0 {
+		return FaultRule{}, fmt.Errorf("a fault needs a latency or an errorRate")
+	}
+	if duration <= 0 {
+		return FaultRule{}, fmt.Errorf("duration must be positive")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rule.ID = f.nextID
+	f.nextID++
+	rule.Duration = duration.String()
+	rule.expires = time.Now().Add(duration)
+	rule.ExpiresAt = rule.expires.UTC().Format(time.RFC3339)
+	f.rules = append(f.rules, &rule)
+	f.publish()
+	return rule, nil
+}
+
+// Remove deletes one rule, or all rules when id is 0. It returns how many were removed.
+func (f *faultRegistry) Remove(id int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	kept := f.rules[:0]
+	for _, rule := range f.rules {
+		if id != 0 && rule.ID != id {
+			kept = append(kept, rule)
+		}
+	}
+	n := len(f.rules) - len(kept)
+	f.rules = kept
+	if n > 0 {
+		f.publish()
+	}
+	return n
+}
+
+// Active returns the rules that have not expired, dropping the others
+func (f *faultRegistry) Active() []FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expire(time.Now())
+	list := make([]FaultRule, 0, len(f.rules))
+	for _, rule := range f.rules {
+		list = append(list, *rule)
+	}
+	return list
+}
+
+// match returns the first active rule covering path
+func (f *faultRegistry) match(path string) (FaultRule, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expire(time.Now())
+	for _, rule := range f.rules {
+		if rule.matches(path) {
+			return *rule, true
+		}
+	}
+	return FaultRule{}, false
+}
+
+func (f *faultRegistry) expire(now time.Time) {
+	kept := f.rules[:0]
+	for _, rule := range f.rules {
+		if now.Before(rule.expires) {
+			kept = append(kept, rule)
+		}
+	}
+	expired := len(f.rules) != len(kept)
+	f.rules = kept
+	if expired {
+		f.publish()
+	}
+}
+
+// publish sends the active rules to the event stream, which feeds the dashboard
+func (f *faultRegistry) publish() {
+	list := make([]FaultRule, 0, len(f.rules))
+	for _, rule := range f.rules {
+		list = append(list, *rule)
+	}
+	events.publish("faults", list)
+}
+
+// injectFaults applies the first matching fault rule. Probes are never faulted,
+// so an experiment cannot get the container restarted.
+func injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProbe(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rule, ok := faults.match(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fail := rand.Float64() < rule.ErrorRate
+		trace.SpanFromContext(r.Context()).AddEvent("fault.injected", trace.WithAttributes(
+			attribute.Int("fault.id", rule.ID),
+			attribute.String("fault.latency", rule.latency.String()),
+			attribute.Bool("fault.error", fail),
+		))
+		if rule.latency > 0 {
+			select {
+			case <-time.After(rule.latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fail {
+			err := fmt.Errorf("injected fault %d", rule.ID)
+			markRequestError(r.Context(), err)
+			log.Printf("[DEBUG] Injected fault %d, path: %s, status: %d, traceId: %s", rule.ID, r.URL.Path, rule.Status, getTraceID(r.Context()))
+			writeProblem(w, r, rule.Status, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func registerFaultHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/faults", faultsHandler)
+}
+
+// faultsHandler lists rules (GET), adds one (POST) or removes ?id= or all of them (DELETE).
+// POST takes a FaultRule; duration defaults to 5m.
+func faultsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, faults.Active())
+	case http.MethodPost:
+		var req FaultRule
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		duration := 5 * time.Minute
+		if req.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(req.Duration); err != nil {
+				writeAdminError(w, http.StatusBadRequest, "invalid duration: "+req.Duration)
+				return
+			}
+		}
+		rule, err := faults.Add(req, duration)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("[INFO] Fault %d added, route: %s, latency: %s, errorRate: %g, status: %d, duration: %s",
+			rule.ID, rule.Route, rule.latency, rule.ErrorRate, rule.Status, rule.Duration)
+		writeJSON(w, http.StatusCreated, rule)
+	case http.MethodDelete:
+		id := 0
+		if v := r.URL.Query().Get("id"); v != "" {
+			var err error
+			if id, err = strconv.Atoi(v); err != nil || id <= 0 {
+				writeAdminError(w, http.StatusBadRequest, "invalid id: "+v)
+				return
+			}
+		}
+		n := faults.Remove(id)
+		if id != 0 && n == 0 {
+			writeAdminError(w, http.StatusNotFound, fmt.Sprintf("fault %d not found", id))
+			return
+		}
+		log.Printf("[INFO] Removed %d fault(s)", n)
+		writeJSON(w, http.StatusOK, map[string]int{"removed": n})
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
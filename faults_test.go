@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultRegistry(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FaultRule
+		err  string
+	}{
+		{"error", FaultRule{Route: "/api/hello", ErrorRate: 1}, ""},
+		{"latency", FaultRule{Route: "/api/*", Latency: "10ms"}, ""},
+		{"no route", FaultRule{ErrorRate: 1}, "route"},
+		{"relative route", FaultRule{Route: "api", ErrorRate: 1}, "route"},
+		{"rate out of range", FaultRule{Route: "*", ErrorRate: 2}, "errorRate"},
+		{"success status", FaultRule{Route: "*", ErrorRate: 1, Status: 200}, "status"},
+		{"bad latency", FaultRule{Route: "*", Latency: "soon"}, "latency"},
+		{"no effect", FaultRule{Route: "*"}, "latency or an errorRate"},
+	}
+	registry := &faultRegistry{nextID: 1}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := registry.Add(tt.rule, time.Minute)
+			if tt.err == "" && err != nil {
+				t.Errorf("got %v want no error", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("got %v want an error containing %q", err, tt.err)
+			}
+		})
+	}
+
+	if rule, ok := registry.match("/api/hello"); !ok || rule.ID != 1 || rule.Status != http.StatusInternalServerError {
+		t.Errorf("got %+v for /api/hello, want the first rule with status 500", rule)
+	}
+	if rule, ok := registry.match("/api/time"); !ok || rule.ID != 2 {
+		t.Errorf("got %+v for /api/time, want the prefix rule", rule)
+	}
+	if _, ok := registry.match("/version"); ok {
+		t.Error("/version should not match any rule")
+	}
+	if _, err := registry.Add(FaultRule{Route: "/version", ErrorRate: 1}, time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if got := len(registry.Active()); got != 2 {
+		t.Errorf("got %d active rules want 2 after expiry", got)
+	}
+	if n := registry.Remove(0); n != 2 || len(registry.Active()) != 0 {
+		t.Errorf("removed %d rules, %d left", n, len(registry.Active()))
+	}
+}
+
+func TestInjectFaults(t *testing.T) {
+	defer faults.Remove(0)
+	if _, err := faults.Add(FaultRule{Route: "*", ErrorRate: 1, Status: http.StatusServiceUnavailable}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	handler := injectFaults(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/api/hello", http.StatusServiceUnavailable},
+		{"/health", http.StatusOK},
+		{"/ready", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+			if rr.Code != tt.expected {
+				t.Errorf("got %v want %v", rr.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFaultsHandler(t *testing.T) {
+	defer faults.Remove(0)
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		expected int
+	}{
+		{"add", "POST", "/admin/faults", `{"route":"/api/random","latency":"50ms","duration":"1m"}`, http.StatusCreated},
+		{"invalid rule", "POST", "/admin/faults", `{"route":"/api/random"}`, http.StatusBadRequest},
+		{"invalid duration", "POST", "/admin/faults", `{"route":"/api/random","errorRate":1,"duration":"later"}`, http.StatusBadRequest},
+		{"list", "GET", "/admin/faults", "", http.StatusOK},
+		{"remove missing", "DELETE", "/admin/faults?id=999", "", http.StatusNotFound},
+		{"remove all", "DELETE", "/admin/faults", "", http.StatusOK},
+		{"wrong method", "PUT", "/admin/faults", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			faultsHandler(rr, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if rr.Code != tt.expected {
+				t.Errorf("got %v want %v: %s", rr.Code, tt.expected, rr.Body)
+			}
+			if tt.name == "list" {
+				var rules []FaultRule
+				if err := json.Unmarshal(rr.Body.Bytes(), &rules); err != nil || len(rules) != 1 || rules[0].Latency != "50ms" {
+					t.Errorf("unexpected rules %s: %v", rr.Body, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFaultRegistryPublishes(t *testing.T) {
+	ch, _, _ := events.subscribe(0, false)
+	defer events.unsubscribe(ch)
+	registry := &faultRegistry{nextID: 1}
+
+	next := func() []FaultRule {
+		t.Helper()
+		e := <-ch
+		if e.Type != "faults" {
+			t.Fatalf("got event type %q want faults", e.Type)
+		}
+		var rules []FaultRule
+		if err := json.Unmarshal(e.Data, &rules); err != nil {
+			t.Fatal(err)
+		}
+		return rules
+	}
+	if _, err := registry.Add(FaultRule{Route: "/api/hello", ErrorRate: 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if rules := next(); len(rules) != 1 || rules[0].Route != "/api/hello" {
+		t.Errorf("got %+v after add, want the new rule", rules)
+	}
+	if _, err := registry.Add(FaultRule{Route: "/version", ErrorRate: 1}, time.Nanosecond); err != nil {
+		t.Fatal(err)
+	}
+	next()
+	time.Sleep(time.Millisecond)
+	registry.Active()
+	if rules := next(); len(rules) != 1 {
+		t.Errorf("got %d rules after expiry want 1", len(rules))
+	}
+	registry.Remove(0)
+	if rules := next(); len(rules) != 0 {
+		t.Errorf("got %d rules after remove want 0", len(rules))
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GenerateOptions shapes the synthetic traces emitted by the generate subcommand
+type GenerateOptions struct {
+	Service     string
+	Exporter    string        // otlp or none
+	Rate        float64       // traces per second, 0 for as fast as possible
+	Duration    time.Duration // 0 for no time limit
+	Count       int           // 0 for no trace limit
+	Depth       int           // levels of spans below the root
+	FanOut      int           // children of every span above the leaves
+	Cardinality int           // distinct values of user.id and cache.key
+	ErrorRate   float64       // fraction of traces that fail
+	LinkRate    float64       // fraction of traces linking to an earlier trace
+	QueueSize   int
+	Seed        int64
+}
+
+// GenerateReport summarises a generator run. Dropped spans were started but never
+// reached the exporter, because the batch queue was full.
+type GenerateReport struct {
+	Traces   int64
+	Spans    int64
+	Exported int64
+	Failed   int64
+	Dropped  int64
+	Elapsed  time.Duration
+}
+
+func (r GenerateReport) String() string {
+	secs := r.Elapsed.Seconds()
+	if secs <= 0 {
+		secs = 1
+	}
+	return fmt.Sprintf("Generated %d traces (%d spans) in %s: %.1f traces/s, %.1f spans/s\nExported %d spans, failed %d, dropped %d\n",
+		r.Traces, r.Spans, r.Elapsed.Round(time.Millisecond), float64(r.Traces)/secs, float64(r.Spans)/secs,
+		r.Exported, r.Failed, r.Dropped)
+}
+
+// generatedRoutes pairs each root route with the handler span the app creates for it
+var generatedRoutes = []struct{ route, handler string }{
+	{"/api/hello", "helloHandler.processGreeting"},
+	{"/api/status", "statusHandler.getStatus"},
+	{"/api/echo", "echoHandler.processRequest"},
+	{"/api/random", "randomHandler.generateData"},
+}
+
+// countingExporter records how many spans reached the wrapped exporter
+type countingExporter struct {
+	sdktrace.SpanExporter
+	exported, failed atomic.Int64
+}
+
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		e.failed.Add(int64(len(spans)))
+	} else {
+		e.exported.Add(int64(len(spans)))
+	}
+	return err
+}
+
+// discardExporter measures generator throughput without a collector
+type discardExporter struct{}
+
+func (discardExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error { return nil }
+func (discardExporter) Shutdown(context.Context) error                             { return nil }
+
+type generator struct {
+	opts    GenerateOptions
+	tracer  trace.Tracer
+	rng     *rand.Rand
+	recent  []trace.SpanContext // earlier roots, targets for span links
+	traces  int64
+	spans   int64
+	failing bool // the current trace still has to record its error
+}
+
+// generateTrace emits one complete trace starting at start, using recorded
+// timestamps instead of sleeping so the rate is not bounded by span latency
+func (g *generator) generateTrace(ctx context.Context, start time.Time) {
+	route := generatedRoutes[g.rng.Intn(len(generatedRoutes))]
+	g.failing = g.rng.Float64() < g.opts.ErrorRate
+	failed := g.failing
+	status := 200
+	if failed {
+		status = 500
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod("GET"),
+			semconv.HTTPRoute(route.route),
+			semconv.HTTPStatusCode(status),
+			attribute.String("user.id", fmt.Sprintf("user-%d", g.rng.Intn(g.opts.Cardinality))),
+		),
+	}
+	if len(g.recent) > 0 && g.rng.Float64() < g.opts.LinkRate {
+		opts = append(opts, trace.WithLinks(trace.Link{
+			SpanContext: g.recent[g.rng.Intn(len(g.recent))],
+			Attributes:  []attribute.KeyValue{attribute.String("link.reason", "synthetic")},
+		}))
+	}
+	ctx, root := g.tracer.Start(ctx, "demo-app", opts...)
+	g.spans++
+
+	end := start.Add(g.gap())
+	for i := 0; g.opts.Depth > 0 && i < g.opts.FanOut; i++ {
+		end = g.emit(ctx, route.handler, 1, end)
+	}
+	if failed {
+		root.SetStatus(codes.Error, "synthetic failure")
+	}
+	root.End(trace.WithTimestamp(end.Add(g.gap())))
+	g.traces++
+
+	if len(g.recent) < 64 {
+		g.recent = append(g.recent, root.SpanContext())
+	} else {
+		g.recent[g.rng.Intn(len(g.recent))] = root.SpanContext()
+	}
+}
+
+// emit starts a span at level below the root and returns when it ended. Spans above
+// the leaves carry the route's handler name; leaves reuse the app's span shapes.
+func (g *generator) emit(ctx context.Context, handler string, level int, start time.Time) time.Time {
+	g.spans++
+	if level < g.opts.Depth {
+		ctx, span := g.tracer.Start(ctx, handler, trace.WithTimestamp(start))
+		end := start.Add(g.gap())
+		for i := 0; i < g.opts.FanOut; i++ {
+			end = g.emit(ctx, handler, level+1, end)
+		}
+		end = end.Add(g.gap())
+		span.End(trace.WithTimestamp(end))
+		return end
+	}
+
+	var name string
+	var attrs []attribute.KeyValue
+	var latency time.Duration
+	switch g.rng.Intn(3) {
+	case 0:
+		name, latency = "database.query", time.Duration(g.rng.Intn(30))*time.Millisecond
+		attrs = []attribute.KeyValue{attribute.String("db.system", "postgresql")}
+	case 1:
+		name, latency = "cache.lookup", time.Duration(g.rng.Intn(10))*time.Millisecond
+		attrs = []attribute.KeyValue{
+			attribute.String("cache.type", "redis"),
+			attribute.Bool("cache.hit", g.rng.Float32() > 0.5),
+			attribute.String("cache.key", fmt.Sprintf("item:%d", g.rng.Intn(g.opts.Cardinality))),
+		}
+	default:
+		name, latency = "external.api.call", time.Duration(g.rng.Intn(100))*time.Millisecond
+		attrs = []attribute.KeyValue{attribute.String("api.name", "random-generator")}
+	}
+	_, span := g.tracer.Start(ctx, name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	end := start.Add(latency)
+	if g.failing {
+		span.RecordError(errors.New("synthetic failure"), trace.WithTimestamp(end))
+		span.SetStatus(codes.Error, "synthetic failure")
+		g.failing = false
+	}
+	span.End(trace.WithTimestamp(end))
+	return end
+}
+
+// gap is the time a span spends in its own code between children
+func (g *generator) gap() time.Duration {
+	return time.Duration(g.rng.Intn(1000)) * time.Microsecond
+}
+
+// run emits traces at opts.Rate until the duration or count is reached or ctx is done
+func (g *generator) run(ctx context.Context) {
+	var deadline <-chan time.Time
+	if g.opts.Duration > 0 {
+		timer := time.NewTimer(g.opts.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		due := g.traces + 1000
+		if g.opts.Rate > 0 {
+			due = int64(g.opts.Rate*time.Since(start).Seconds()) + 1
+		}
+		for g.traces < due {
+			if g.opts.Count > 0 && g.traces >= int64(g.opts.Count) {
+				return
+			}
+			g.generateTrace(ctx, time.Now())
+		}
+
+		if g.opts.Rate > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline:
+				return
+			case <-ticker.C:
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		default:
+		}
+	}
+}
+
+// generate runs the generator against exporter and waits for every queued span to
+// be exported, so the report accounts for all of them
+func generate(ctx context.Context, opts GenerateOptions, exporter sdktrace.SpanExporter) (GenerateReport, error) {
+	res, err := newResource(opts.Service, attribute.Bool("demo.synthetic", true))
+	if err != nil {
+		return GenerateReport{}, err
+	}
+	counter := &countingExporter{SpanExporter: exporter}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(counter, sdktrace.WithMaxQueueSize(opts.QueueSize)),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+
+	g := &generator{
+		opts:   opts,
+		tracer: tp.Tracer("demo-app-generator"),
+		rng:    rand.New(rand.NewSource(opts.Seed)),
+	}
+	start := time.Now()
+	g.run(ctx)
+	elapsed := time.Since(start)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = tp.Shutdown(shutdownCtx)
+
+	report := GenerateReport{
+		Traces:   g.traces,
+		Spans:    g.spans,
+		Exported: counter.exported.Load(),
+		Failed:   counter.failed.Load(),
+		Elapsed:  elapsed,
+	}
+	report.Dropped = report.Spans - report.Exported - report.Failed
+	return report, err
+}
+
+// maxSpansPerTrace bounds -depth and -fanout: a trace has fanout^0 + ... + fanout^depth
+// spans, which is built in memory and recursed into before anything is exported
+const maxSpansPerTrace = 10000
+
+// spansPerTrace counts the spans of one generated trace, stopping once it exceeds limit
+func spansPerTrace(depth, fanout, limit int) int {
+	total, level := 1, 1
+	for i := 0; i < depth && total <= limit; i++ {
+		level *= fanout
+		if level > limit {
+			return limit + 1
+		}
+		total += level
+	}
+	return total
+}
+
+// parseGenerateFlags reads the generate subcommand flags, defaulting from cfg
+func parseGenerateFlags(args []string, cfg *Config, output io.Writer) (GenerateOptions, error) {
+	opts := GenerateOptions{}
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.Service, "service", cfg.ServiceName, "service.name of the generated spans")
+	fs.StringVar(&opts.Exporter, "exporter", "otlp", "otlp sends to OTEL_EXPORTER_OTLP_ENDPOINT, none discards spans")
+	fs.Float64Var(&opts.Rate, "rate", 100, "traces per second, 0 for as fast as possible")
+	fs.DurationVar(&opts.Duration, "duration", 10*time.Second, "how long to generate for, 0 for no limit")
+	fs.IntVar(&opts.Count, "count", 0, "stop after this many traces, 0 for no limit")
+	fs.IntVar(&opts.Depth, "depth", 2, "levels of spans below the root")
+	fs.IntVar(&opts.FanOut, "fanout", 3, "children of every span above the leaves")
+	fs.IntVar(&opts.Cardinality, "cardinality", 100, "distinct values of user.id and cache.key")
+	fs.Float64Var(&opts.ErrorRate, "error-rate", 0.05, "fraction of traces that fail")
+	fs.Float64Var(&opts.LinkRate, "link-rate", 0.1, "fraction of traces linking to an earlier trace")
+	fs.IntVar(&opts.QueueSize, "queue-size", 2048, "batch span processor queue size")
+	fs.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "random seed, fixed for reproducible traces")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	switch {
+	case fs.NArg() > 0:
+		return opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	case opts.Exporter != "otlp" && opts.Exporter != "none":
+		return opts, fmt.Errorf("-exporter must be otlp or none, got %q", opts.Exporter)
+	case opts.Rate < 0, opts.Duration < 0, opts.Count < 0, opts.Depth < 0:
+		return opts, errors.New("-rate, -duration, -count and -depth must not be negative")
+	case opts.FanOut < 1, opts.Cardinality < 1, opts.QueueSize < 1:
+		return opts, errors.New("-fanout, -cardinality and -queue-size must be at least 1")
+	case opts.ErrorRate < 0 || opts.ErrorRate > 1, opts.LinkRate < 0 || opts.LinkRate > 1:
+		return opts, errors.New("-error-rate and -link-rate must be between 0 and 1")
+	case spansPerTrace(opts.Depth, opts.FanOut, maxSpansPerTrace) > maxSpansPerTrace:
+		return opts, fmt.Errorf("-depth %d and -fanout %d exceed %d spans per trace", opts.Depth, opts.FanOut, maxSpansPerTrace)
+	}
+	return opts, nil
+}
+
+// runGenerate implements `demo-app generate`: synthetic traces through the
+// configured exporter, without HTTP in the way
+func runGenerate(args []string) int {
+	cfg, err := readConfig()
+	if err != nil {
+		log.Printf("[ERROR] Invalid configuration: %v", err)
+		return 1
+	}
+	opts, err := parseGenerateFlags(args, cfg, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "generate: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var exporter sdktrace.SpanExporter = discardExporter{}
+	if opts.Exporter == "otlp" {
+		if exporter, err = newExporter(ctx, cfg); err != nil {
+			log.Printf("[ERROR] %v", err)
+			return 1
+		}
+		log.Printf("[INFO] Sending synthetic traces to %s", cfg.OTLPEndpoint)
+	}
+	log.Printf("[INFO] Generating traces, rate: %g/s, depth: %d, fanout: %d, duration: %s, count: %d",
+		opts.Rate, opts.Depth, opts.FanOut, opts.Duration, opts.Count)
+
+	report, err := generate(ctx, opts, exporter)
+	fmt.Print(report)
+	if err != nil {
+		log.Printf("[ERROR] Exporter shutdown failed: %v", err)
+		return 1
+	}
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
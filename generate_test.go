@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestParseGenerateFlags(t *testing.T) {
+	cfg := loadConfig()
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"defaults", nil, ""},
+		{"all flags", []string{"-rate=0", "-count=10", "-depth=4", "-fanout=1", "-error-rate=1", "-link-rate=0", "-exporter=none"}, ""},
+		{"unknown exporter", []string{"-exporter=zipkin"}, "-exporter"},
+		{"negative depth", []string{"-depth=-1"}, "must not be negative"},
+		{"zero fanout", []string{"-fanout=0"}, "at least 1"},
+		{"error rate", []string{"-error-rate=1.5"}, "between 0 and 1"},
+		{"extra argument", []string{"now"}, "unexpected arguments"},
+		{"unknown flag", []string{"-spans=5"}, "not defined"},
+		{"span budget", []string{"-depth=3", "-fanout=21"}, ""},
+		{"too many spans", []string{"-depth=4", "-fanout=10"}, "spans per trace"},
+		{"deep chain", []string{"-depth=20000", "-fanout=1"}, "spans per trace"},
+		{"huge fanout", []string{"-depth=3", "-fanout=2000000000"}, "spans per trace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseGenerateFlags(tt.args, cfg, io.Discard)
+			checkErr(t, err, tt.err)
+			if err == nil && opts.Service != cfg.ServiceName {
+				t.Errorf("service should default to %q, got %q", cfg.ServiceName, opts.Service)
+			}
+		})
+	}
+}
+
+// keptSpans ignores Shutdown, which would otherwise clear the recorded spans
+type keptSpans struct{ *tracetest.InMemoryExporter }
+
+func (keptSpans) Shutdown(context.Context) error { return nil }
+
+func TestGenerate(t *testing.T) {
+	exporter := keptSpans{tracetest.NewInMemoryExporter()}
+	opts := GenerateOptions{
+		Service: "generator-test", Count: 20, Depth: 2, FanOut: 3,
+		Cardinality: 5, ErrorRate: 1, LinkRate: 1, QueueSize: 2048, Seed: 1,
+	}
+	report, err := generate(context.Background(), opts, exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each trace is the root, 3 handler spans and 9 leaves
+	if report.Traces != 20 || report.Spans != 20*13 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Exported != report.Spans || report.Dropped != 0 || report.Failed != 0 {
+		t.Errorf("spans were lost: %+v", report)
+	}
+
+	names := make(map[string]int)
+	traces := make(map[string]bool)
+	var links, errorsRecorded int
+	for _, span := range exporter.GetSpans() {
+		names[span.Name]++
+		traces[span.SpanContext.TraceID().String()] = true
+		links += len(span.Links)
+		if span.Status.Code == codes.Error && span.Name != "demo-app" {
+			errorsRecorded++
+		}
+	}
+	if len(traces) != 20 || names["demo-app"] != 20 {
+		t.Errorf("expected 20 traces with one root each, got %d traces and %v", len(traces), names)
+	}
+	if names["database.query"]+names["cache.lookup"]+names["external.api.call"] != 20*9 {
+		t.Errorf("leaves should reuse the app's span shapes: %v", names)
+	}
+	// Every trace but the first links back to an earlier one
+	if links != 19 {
+		t.Errorf("expected 19 span links, got %d", links)
+	}
+	if errorsRecorded != 20 {
+		t.Errorf("expected one failing leaf per trace, got %d", errorsRecorded)
+	}
+}
+
+func TestGenerateRate(t *testing.T) {
+	opts := GenerateOptions{
+		Service: "generator-test", Rate: 200, Duration: 250 * time.Millisecond, Depth: 1, FanOut: 1,
+		Cardinality: 1, QueueSize: 1, Seed: 1,
+	}
+	report, err := generate(context.Background(), opts, discardExporter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Traces < 25 || report.Traces > 75 {
+		t.Errorf("expected about 50 traces at 200/s for 250ms, got %d", report.Traces)
+	}
+	if report.Exported+report.Dropped != report.Spans {
+		t.Errorf("every span should be exported or dropped: %+v", report)
+	}
+	if !strings.Contains(report.String(), "dropped") {
+		t.Errorf("report does not mention dropped spans: %s", report)
+	}
+}
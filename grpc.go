@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	demov1 "github.com/demo/demo-app/proto/demo/v1"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// demoService implements demov1.DemoServiceServer with the same behaviour as the HTTP handlers
+type demoService struct {
+	demov1.UnimplementedDemoServiceServer
+}
+
+func (demoService) Hello(ctx context.Context, req *demov1.HelloRequest) (*demov1.HelloResponse, error) {
+	atomic.AddInt64(&requestCount, 1)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("greeting.name", req.GetName()))
+
+	// Simulate some processing time
+	time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
+
+	log.Printf("[INFO] gRPC Hello called, name: %s, traceId: %s", req.GetName(), getTraceID(ctx))
+	return &demov1.HelloResponse{
+		Message:   greeting(ctx, req.GetName()),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		TraceId:   getTraceID(ctx),
+	}, nil
+}
+
+func (demoService) Echo(ctx context.Context, req *demov1.EchoRequest) (*demov1.EchoResponse, error) {
+	atomic.AddInt64(&requestCount, 1)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := make(map[string]string, len(md))
+	for key, v := range md {
+		if len(v) > 0 {
+			values[key] = v[0]
+		}
+	}
+	// Never echo credentials back
+	for _, key := range []string{"authorization", "x-api-key", "cookie"} {
+		if _, ok := values[key]; ok {
+			values[key] = "[redacted]"
+		}
+	}
+
+	echo := req.GetMessage()
+	if echo == "" {
+		echo = printerFromContext(ctx).Sprintf("echo.default")
+	}
+	method, _ := grpc.Method(ctx)
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+
+	log.Printf("[INFO] gRPC Echo called, message: %s, traceId: %s", echo, getTraceID(ctx))
+	return &demov1.EchoResponse{
+		Echo:      echo,
+		Metadata:  values,
+		Method:    method,
+		Peer:      addr,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		TraceId:   getTraceID(ctx),
+	}, nil
+}
+
+func (demoService) Status(ctx context.Context, _ *demov1.StatusRequest) (*demov1.StatusResponse, error) {
+	resp := newStatusResponse(ctx)
+	log.Printf("[INFO] gRPC Status called, env: %s, uptime: %s, traceId: %s", resp.Environment, resp.Uptime, getTraceID(ctx))
+	return &demov1.StatusResponse{
+		Status:      resp.Status,
+		Environment: resp.Environment,
+		Uptime:      resp.Uptime,
+		Timestamp:   resp.Timestamp,
+		TraceId:     resp.TraceID,
+	}, nil
+}
+
+func (demoService) Time(ctx context.Context, _ *demov1.TimeRequest) (*demov1.TimeResponse, error) {
+	atomic.AddInt64(&requestCount, 1)
+	resp := newTimeResponse(time.Now())
+	log.Printf("[INFO] gRPC Time called, serverTime: %s, traceId: %s", resp.ServerTime, getTraceID(ctx))
+	return &demov1.TimeResponse{
+		ServerTime: resp.ServerTime,
+		Timezone:   resp.Timezone,
+		UnixTime:   resp.UnixTime,
+		DayOfWeek:  resp.DayOfWeek,
+		WeekOfYear: int32(resp.WeekOfYear),
+		IsWeekend:  resp.IsWeekend,
+		Timestamp:  resp.Timestamp,
+	}, nil
+}
+
+func (demoService) Random(ctx context.Context, _ *demov1.RandomRequest) (*demov1.RandomResponse, error) {
+	atomic.AddInt64(&requestCount, 1)
+	resp := newRandomResponse(ctx)
+	dice := make([]int32, len(resp.Dice))
+	for i, d := range resp.Dice {
+		dice[i] = int32(d)
+	}
+	return &demov1.RandomResponse{
+		Number:      int32(resp.Number),
+		Uuid:        resp.UUID,
+		Color:       resp.Color,
+		Quote:       resp.Quote,
+		LuckyNumber: int32(resp.LuckyNumber),
+		Dice:        dice,
+		Timestamp:   resp.Timestamp,
+		TraceId:     resp.TraceID,
+	}, nil
+}
+
+// logUnary logs every unary call with its status code and latency
+func logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+	level := "[INFO]"
+	if code != codes.OK {
+		level = "[WARN]"
+	}
+	log.Printf("%s gRPC %s, code: %s, duration: %s, traceId: %s",
+		level, info.FullMethod, code, time.Since(start).Round(time.Microsecond), getTraceID(ctx))
+	return resp, err
+}
+
+// grpcRequest describes a call as the HTTP request to the matching /api route, so
+// the HTTP authentication, RBAC, rate limit and priority settings apply to it too.
+// The demo RPCs only read, like the GET handlers they mirror.
+func grpcRequest(ctx context.Context, fullMethod string) *http.Request {
+	_, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	r := (&http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: "/api/" + strings.ToLower(method)},
+		Header: make(http.Header),
+	}).WithContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if !strings.HasPrefix(key, ":") {
+			r.Header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	return r
+}
+
+// grpcCode maps the status the HTTP middleware would have answered with
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	default:
+		return codes.Unknown
+	}
+}
+
+// guardUnary applies load shedding, rate limiting, authentication and RBAC in the
+// same order as the middleware in front of /api
+func guardUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	r := grpcRequest(ctx, info.FullMethod)
+
+	if l := limiter; l.cfg.MaxInFlight > 0 {
+		if !l.admit(ctx, r.URL.Path) {
+			return nil, status.Error(codes.Unavailable, "server is overloaded, retry later")
+		}
+		defer l.release()
+	}
+	if policy, _, limited, allowed := rateLimits.admit(r); limited && !allowed {
+		return nil, status.Error(codes.ResourceExhausted, policy.exceeded())
+	}
+	if a := auth.Load(); a.cfg.Enabled {
+		p, code, err := a.admit(r)
+		if err != nil {
+			return nil, status.Error(grpcCode(code), err.Error())
+		}
+		if p != nil {
+			ctx = context.WithValue(ctx, principalKey{}, p)
+			r = r.WithContext(ctx)
+		}
+	}
+	if cfg := rbac.Load(); cfg.Enabled {
+		if code, err := cfg.permit(r); err != nil {
+			return nil, status.Error(grpcCode(code), err.Error())
+		}
+	}
+	return handler(ctx, req)
+}
+
+// newGRPCServer builds the instrumented server with the demo, health and reflection services
+func newGRPCServer() (*grpc.Server, *health.Server) {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(logUnary, localizeUnary, guardUnary),
+	)
+	demov1.RegisterDemoServiceServer(srv, demoService{})
+
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	reflection.Register(srv)
+	return srv, healthSrv
+}
+
+// reportHealth keeps the gRPC health service in step with the health registry:
+// NOT_SERVING while any component has failed
+func reportHealth(healthSrv *health.Server) (stop func()) {
+	return watchHealth(func(ok bool) {
+		serving := healthpb.HealthCheckResponse_SERVING
+		if !ok {
+			serving = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		for _, service := range []string{"", demov1.DemoService_ServiceDesc.ServiceName} {
+			healthSrv.SetServingStatus(service, serving)
+		}
+	})
+}
+
+// startGRPCServer serves gRPC on its own port, reporting the app's health once the listener is up
+func startGRPCServer(cfg *Config) (*grpc.Server, error) {
+	ln, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on :%s: %w", cfg.GRPC.Port, err)
+	}
+	srv, healthSrv := newGRPCServer()
+	reportHealth(healthSrv)
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			log.Printf("[ERROR] gRPC server stopped: %v", err)
+		}
+	}()
+	log.Printf("gRPC API listening on :%s (reflection enabled)", cfg.GRPC.Port)
+	return srv, nil
+}
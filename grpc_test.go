@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	demov1 "github.com/demo/demo-app/proto/demo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dialTestServer(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	srv, healthSrv := newGRPCServer()
+	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPCDemoService(t *testing.T) {
+	client := demov1.NewDemoServiceClient(dialTestServer(t))
+	ctx := context.Background()
+
+	hello, err := client.Hello(ctx, &demov1.HelloRequest{})
+	if err != nil || !strings.Contains(hello.Message, "Hello, World!") {
+		t.Errorf("unexpected Hello response: %v, %v", hello, err)
+	}
+	hello, err = client.Hello(ctx, &demov1.HelloRequest{Name: "gRPC"})
+	if err != nil || !strings.Contains(hello.Message, "Hello, gRPC!") {
+		t.Errorf("unexpected Hello response: %v, %v", hello, err)
+	}
+
+	md := metadata.Pairs("authorization", "Bearer secret", "x-request-id", "abc")
+	echo, err := client.Echo(metadata.NewOutgoingContext(ctx, md), &demov1.EchoRequest{Message: "ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if echo.Echo != "ping" || echo.Method != "/demo.v1.DemoService/Echo" {
+		t.Errorf("unexpected Echo response: %v", echo)
+	}
+	if echo.Metadata["authorization"] != "[redacted]" || echo.Metadata["x-request-id"] != "abc" {
+		t.Errorf("unexpected Echo metadata: %v", echo.Metadata)
+	}
+
+	status, err := client.Status(ctx, &demov1.StatusRequest{})
+	if err != nil || status.Status != "running" {
+		t.Errorf("unexpected Status response: %v, %v", status, err)
+	}
+
+	tm, err := client.Time(ctx, &demov1.TimeRequest{})
+	if err != nil || tm.UnixTime == 0 || tm.DayOfWeek == "" {
+		t.Errorf("unexpected Time response: %v, %v", tm, err)
+	}
+
+	random, err := client.Random(ctx, &demov1.RandomRequest{})
+	if err != nil || len(random.Dice) != 3 || random.Uuid == "" {
+		t.Errorf("unexpected Random response: %v, %v", random, err)
+	}
+}
+
+func TestGRPCHealthAndReflection(t *testing.T) {
+	conn := dialTestServer(t)
+	ctx := context.Background()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("unexpected health response: %v, %v", resp, err)
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	reply, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var services []string
+	for _, s := range reply.GetListServicesResponse().GetService() {
+		services = append(services, s.Name)
+	}
+	if !strings.Contains(strings.Join(services, ","), "demo.v1.DemoService") {
+		t.Errorf("reflection does not list the demo service: %v", services)
+	}
+}
+
+func TestGRPCGuard(t *testing.T) {
+	setupAuth(t)
+	oldRBAC := rbac.Load()
+	t.Cleanup(func() { rbac.Store(oldRBAC) })
+	client := demov1.NewDemoServiceClient(dialTestServer(t))
+
+	tests := []struct {
+		name     string
+		call     func(context.Context) error
+		apiKey   string
+		rbac     bool
+		expected codes.Code
+	}{
+		{"optional auth", func(ctx context.Context) error { _, err := client.Hello(ctx, &demov1.HelloRequest{}); return err }, "", false, codes.OK},
+		{"required auth", func(ctx context.Context) error { _, err := client.Status(ctx, &demov1.StatusRequest{}); return err }, "", false, codes.Unauthenticated},
+		{"invalid key", func(ctx context.Context) error { _, err := client.Status(ctx, &demov1.StatusRequest{}); return err }, "nope", false, codes.Unauthenticated},
+		{"scope granted", func(ctx context.Context) error { _, err := client.Echo(ctx, &demov1.EchoRequest{}); return err }, "k-123", false, codes.OK},
+		{"no role", func(ctx context.Context) error { _, err := client.Status(ctx, &demov1.StatusRequest{}); return err }, "k-123", true, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig().RBAC
+			cfg.Enabled = tt.rbac
+			rbac.Store(&cfg)
+
+			ctx := context.Background()
+			if tt.apiKey != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", tt.apiKey)
+			}
+			if code := status.Code(tt.call(ctx)); code != tt.expected {
+				t.Errorf("got %s want %s", code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReportHealth(t *testing.T) {
+	healthSrv := health.NewServer()
+	stop := reportHealth(healthSrv)
+	defer stop()
+	defer healthState.Delete("zz-test")
+
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := healthSrv.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "demo.v1.DemoService"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Status
+	}
+	if s := check(); s != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING initially, got %s", s)
+	}
+	setHealth("zz-test", "fail", "broken")
+	if s := check(); s != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING after a failure, got %s", s)
+	}
+	setHealth("zz-test", "ok", "recovered")
+	if s := check(); s != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING after recovery, got %s", s)
+	}
+}
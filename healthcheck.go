@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HealthcheckOptions controls a probe of the healthcheck subcommand
+type HealthcheckOptions struct {
+	URL        string
+	Socket     string // dial this Unix socket instead of the URL's host
+	Timeout    time.Duration
+	Insecure   bool
+	CAFile     string
+	ServerName string // name to verify the certificate against, when it does not cover the URL host
+	CertFile   string // client certificate, for TLS_CLIENT_AUTH=require
+	KeyFile    string
+}
+
+func (opts HealthcheckOptions) client() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.Insecure, ServerName: opts.ServerName}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", opts.CAFile)
+		}
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := &http.Transport{DisableKeepAlives: true, TLSClientConfig: tlsConfig}
+	if opts.Socket != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", opts.Socket)
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		// A redirect means the probe is pointed at the wrong place
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}, nil
+}
+
+// probeHealth reports why the target is unhealthy, or how it answered
+func probeHealth(ctx context.Context, opts HealthcheckOptions) (string, error) {
+	client, err := opts.client()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "demo-app-healthcheck/"+Version)
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "", fmt.Errorf("no response within %s", opts.Timeout)
+		}
+		// Drop the method and URL, the operator knows what was probed
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %s", resp.Status)
+	}
+	var health Response
+	if json.Unmarshal(body, &health) == nil && health.Status != "" && health.Status != "healthy" && health.Status != "ready" {
+		return "", fmt.Errorf("status %q", health.Status)
+	}
+	return fmt.Sprintf("%s in %s", resp.Status, time.Since(start).Round(time.Millisecond)), nil
+}
+
+// parseHealthcheckFlags defaults to the readiness endpoint of the local listener
+// described by cfg: the Unix socket when one is configured, otherwise PORT over
+// HTTPS when TLS is enabled. Certificates are verified whenever -ca is given.
+func parseHealthcheckFlags(args []string, cfg *Config, output io.Writer) (HealthcheckOptions, error) {
+	var opts HealthcheckOptions
+	var path string
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.URL, "url", "", "URL to probe, default from PORT, TLS_ENABLED and -path")
+	fs.StringVar(&path, "path", "/ready", "path probed when -url is not given")
+	fs.StringVar(&opts.Socket, "socket", cfg.Socket, "probe over this Unix socket")
+	fs.DurationVar(&opts.Timeout, "timeout", 3*time.Second, "give up after this long")
+	fs.BoolVar(&opts.Insecure, "insecure", false, "skip TLS certificate verification, the default without -ca")
+	fs.StringVar(&opts.CAFile, "ca", "", "CA bundle to verify the server with")
+	fs.StringVar(&opts.ServerName, "servername", "", "name to verify the certificate against instead of the URL host")
+	fs.StringVar(&opts.CertFile, "cert", "", "client certificate, when client auth is required")
+	fs.StringVar(&opts.KeyFile, "key", "", "client certificate key")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	// Without a CA to verify against, a self-signed certificate for some other name
+	// than localhost must not fail the probe
+	insecureSet := false
+	fs.Visit(func(f *flag.Flag) { insecureSet = insecureSet || f.Name == "insecure" })
+	if !insecureSet {
+		opts.Insecure = opts.CAFile == ""
+	}
+
+	if opts.URL == "" {
+		switch {
+		case opts.Socket != "":
+			opts.URL = "http://localhost" + path
+		case cfg.TLS.Enabled:
+			opts.URL = "https://localhost:" + cfg.Port + path
+		default:
+			opts.URL = "http://localhost:" + cfg.Port + path
+		}
+	}
+	switch {
+	case fs.NArg() > 0:
+		return opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	case opts.Timeout <= 0:
+		return opts, errors.New("-timeout must be positive")
+	case (opts.CertFile == "") != (opts.KeyFile == ""):
+		return opts, errors.New("-cert and -key must be given together")
+	}
+	return opts, nil
+}
+
+// runHealthcheck implements `demo-app healthcheck` for Docker HEALTHCHECK. Every
+// failure exits 1, since Docker reserves exit status 2.
+func runHealthcheck(args []string) int {
+	cfg, err := readConfig()
+	if err != nil {
+		fmt.Printf("unhealthy: invalid configuration: %v\n", err)
+		return 1
+	}
+	opts, err := parseHealthcheckFlags(args, cfg, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Printf("unhealthy: %v\n", err)
+		return 1
+	}
+
+	reason, err := probeHealth(context.Background(), opts)
+	if err != nil {
+		fmt.Printf("unhealthy: %v\n", err)
+		return 1
+	}
+	fmt.Printf("healthy: %s\n", reason)
+	return 0
+}
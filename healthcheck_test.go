@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeHealth(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(healthHandler))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusServiceUnavailable, "shutting down")
+	}))
+	defer failing.Close()
+	degraded := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Response{Status: "degraded"})
+	}))
+	defer degraded.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+	ready := httptest.NewServer(http.HandlerFunc(readyHandler))
+	defer ready.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(healthHandler))
+	defer secure.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: secure.Certificate().Raw}), 0o600)
+
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	if _, err := serveUnixSocket(socket, http.HandlerFunc(healthHandler), true); err != nil {
+		t.Fatal(err)
+	}
+	// The test certificate covers 127.0.0.1 and example.com, not localhost
+	byName := strings.Replace(secure.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name string
+		opts HealthcheckOptions
+		err  string
+	}{
+		{"healthy", HealthcheckOptions{URL: healthy.URL + "/health"}, ""},
+		{"ready", HealthcheckOptions{URL: ready.URL + "/ready"}, ""},
+		{"status code", HealthcheckOptions{URL: failing.URL + "/health"}, "503"},
+		{"status field", HealthcheckOptions{URL: degraded.URL + "/health"}, `"degraded"`},
+		{"timeout", HealthcheckOptions{URL: slow.URL + "/health", Timeout: 50 * time.Millisecond}, "no response within 50ms"},
+		{"refused", HealthcheckOptions{URL: closed.URL + "/health"}, "refused"},
+		{"tls insecure", HealthcheckOptions{URL: secure.URL + "/health", Insecure: true}, ""},
+		{"tls verified", HealthcheckOptions{URL: secure.URL + "/health", CAFile: caFile}, ""},
+		{"tls unknown authority", HealthcheckOptions{URL: secure.URL + "/health"}, "certificate"},
+		{"tls wrong name", HealthcheckOptions{URL: byName + "/health", CAFile: caFile}, "certificate"},
+		{"tls server name", HealthcheckOptions{URL: byName + "/health", CAFile: caFile, ServerName: "example.com"}, ""},
+		{"unix socket", HealthcheckOptions{URL: "http://localhost/health", Socket: socket}, ""},
+		{"missing socket", HealthcheckOptions{URL: "http://localhost/health", Socket: socket + ".missing"}, "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.opts.Timeout == 0 {
+				tt.opts.Timeout = 2 * time.Second
+			}
+			reason, err := probeHealth(context.Background(), tt.opts)
+			checkErr(t, err, tt.err)
+			if tt.err == "" && !strings.HasPrefix(reason, "200 OK in ") {
+				t.Errorf("got reason %q want 200 OK", reason)
+			}
+		})
+	}
+}
+
+func TestProbeHealthOptionalListenerDown(t *testing.T) {
+	ready := httptest.NewServer(http.HandlerFunc(readyHandler))
+	defer ready.Close()
+	defer healthState.Delete("admin")
+	opts := HealthcheckOptions{URL: ready.URL + "/ready", Timeout: 2 * time.Second}
+
+	// A busy admin port must not keep the container unhealthy
+	setHealth("admin", "degraded", "listen tcp :9000: bind: address already in use")
+	_, err := probeHealth(context.Background(), opts)
+	checkErr(t, err, "")
+
+	setHealth("admin", "fail", "broken")
+	_, err = probeHealth(context.Background(), opts)
+	checkErr(t, err, "503")
+}
+
+func TestParseHealthcheckFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		cfg      func(*Config)
+		expected string
+		insecure bool
+	}{
+		{"plain", nil, func(*Config) {}, "http://localhost:8000/ready", true},
+		{"tls", nil, func(c *Config) { c.TLS.Enabled = true }, "https://localhost:8000/ready", true},
+		{"socket from config", nil, func(c *Config) { c.Socket = "/run/app.sock" }, "http://localhost/ready", true},
+		{"path", []string{"-path=/health"}, func(*Config) {}, "http://localhost:8000/health", true},
+		{"explicit url", []string{"-url=http://127.0.0.1:9/x"}, func(c *Config) { c.TLS.Enabled = true }, "http://127.0.0.1:9/x", true},
+		{"ca verifies", []string{"-ca=ca.pem"}, func(c *Config) { c.TLS.Enabled = true }, "https://localhost:8000/ready", false},
+		{"ca with insecure", []string{"-ca=ca.pem", "-insecure"}, func(c *Config) { c.TLS.Enabled = true }, "https://localhost:8000/ready", true},
+		{"verify without ca", []string{"-insecure=false"}, func(c *Config) { c.TLS.Enabled = true }, "https://localhost:8000/ready", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			cfg.Port = "8000"
+			tt.cfg(cfg)
+			opts, err := parseHealthcheckFlags(tt.args, cfg, io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.URL != tt.expected {
+				t.Errorf("got %q want %q", opts.URL, tt.expected)
+			}
+			if opts.Insecure != tt.insecure {
+				t.Errorf("insecure is %v, expected %v", opts.Insecure, tt.insecure)
+			}
+		})
+	}
+
+	if _, err := parseHealthcheckFlags([]string{"-cert=client.pem"}, loadConfig(), io.Discard); err == nil {
+		t.Error("-cert without -key should be rejected")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// Message is a catalog entry. Plain strings in the catalog files become Other;
+// objects may give a "one" form for locales that distinguish it.
+type Message struct {
+	One   string `json:"one,omitempty"`
+	Other string `json:"other"`
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		m.Other = s
+		return nil
+	}
+	type plain Message
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+var (
+	catalogs         = loadCatalogs()
+	supportedLocales = sortedLocales(catalogs)
+	i18nConfig       atomic.Pointer[I18nConfig]
+)
+
+func init() {
+	i18nConfig.Store(&I18nConfig{})
+}
+
+// loadCatalogs reads locales/<tag>.json for every embedded locale. locales/default.json
+// is stored under the empty tag: the original text, for requests that name no locale
+// while DEFAULT_LOCALE is unset.
+func loadCatalogs() map[string]map[string]Message {
+	files, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	out := make(map[string]map[string]Message, len(files))
+	for _, f := range files {
+		data, err := localeFS.ReadFile("locales/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		var cat map[string]Message
+		if err := json.Unmarshal(data, &cat); err != nil {
+			panic(fmt.Sprintf("invalid catalog %s: %v", f.Name(), err))
+		}
+		tag := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+		if tag == "default" {
+			tag = ""
+		}
+		out[tag] = cat
+	}
+	return out
+}
+
+func sortedLocales(cats map[string]map[string]Message) []string {
+	var tags []string
+	for tag := range cats {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Printer formats catalog messages for one locale
+type Printer struct {
+	Locale string
+}
+
+// Sprintf looks up key and formats it with args. Missing keys fall back to the
+// default locale and then to the key itself, so a gap never breaks a response.
+func (p Printer) Sprintf(key string, args ...interface{}) string {
+	return p.format(key, -1, args)
+}
+
+// Plural picks the plural form for n and formats it with n as the first argument
+func (p Printer) Plural(key string, n int, args ...interface{}) string {
+	return p.format(key, n, append([]interface{}{n}, args...))
+}
+
+func (p Printer) format(key string, n int, args []interface{}) string {
+	msg, ok := catalogs[p.Locale][key]
+	if !ok {
+		msg, ok = catalogs[i18nConfig.Load().DefaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	text := msg.Other
+	if n >= 0 && msg.One != "" && pluralCategory(p.Locale, n) == "one" {
+		text = msg.One
+	}
+	// A translation may leave out the arguments, such as a count
+	if len(args) == 0 || !strings.Contains(text, "%") {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// pluralCategory implements the CLDR cardinal rules for the supported languages
+func pluralCategory(locale string, n int) string {
+	lang, _, _ := strings.Cut(locale, "-")
+	switch lang {
+	case "zh", "ja", "ko":
+		return "other"
+	}
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// negotiateLocale returns the supported locale that best matches lang (from ?lang=)
+// or the Accept-Language header, falling back to the configured default
+func negotiateLocale(lang, acceptLanguage string) string {
+	if tag := matchLocale(lang); tag != "" {
+		return tag
+	}
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		if tag := matchLocale(name); tag != "" && weight > 0 {
+			candidates = append(candidates, candidate{tag, weight})
+		}
+	}
+	// Stable, so equal weights keep the client's order
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	if len(candidates) > 0 {
+		return candidates[0].tag
+	}
+	return i18nConfig.Load().DefaultLocale
+}
+
+// matchLocale maps a language tag to a supported locale, exactly or by base language
+func matchLocale(tag string) string {
+	tag = strings.TrimSpace(strings.ReplaceAll(tag, "_", "-"))
+	if tag == "" || tag == "*" {
+		return ""
+	}
+	for _, l := range supportedLocales {
+		if strings.EqualFold(l, tag) {
+			return l
+		}
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	for _, l := range supportedLocales {
+		if lb, _, _ := strings.Cut(l, "-"); strings.EqualFold(lb, base) {
+			return l
+		}
+	}
+	return ""
+}
+
+type printerKey struct{}
+
+func withPrinter(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, printerKey{}, Printer{Locale: locale})
+}
+
+// printerFromContext returns the request's printer, or one for the default locale
+func printerFromContext(ctx context.Context) Printer {
+	if p, ok := ctx.Value(printerKey{}).(Printer); ok {
+		return p
+	}
+	return Printer{Locale: i18nConfig.Load().DefaultLocale}
+}
+
+// localize selects the response locale and reports it in Content-Language and on the
+// span. Neither is set when the request falls back to the default text.
+func localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := negotiateLocale(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+		h := w.Header()
+		if locale != "" {
+			h.Set("Content-Language", locale)
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("app.locale", locale))
+		}
+		h.Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(withPrinter(r.Context(), locale)))
+	})
+}
+
+// localizeUnary does the same for gRPC using the accept-language metadata
+func localizeUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	locale := negotiateLocale("", strings.Join(md.Get("accept-language"), ","))
+	if locale != "" {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("app.locale", locale))
+		if err := grpc.SetHeader(ctx, metadata.Pairs("content-language", locale)); err != nil {
+			log.Printf("[DEBUG] Failed to set content-language header: %v", err)
+		}
+	}
+	return handler(withPrinter(ctx, locale), req)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	demov1 "github.com/demo/demo-app/proto/demo/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	def := catalogs["en-US"]
+	for locale, cat := range catalogs {
+		for key := range def {
+			if _, ok := cat[key]; !ok {
+				t.Errorf("%s is missing %q", locale, key)
+			}
+		}
+		for key := range cat {
+			if _, ok := def[key]; !ok {
+				t.Errorf("%s has %q, which en-US does not", locale, key)
+			}
+		}
+	}
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		lang           string
+		acceptLanguage string
+		expected       string
+	}{
+		{"default", "", "", ""},
+		{"query wins", "zh-CN", "en-US", "zh-CN"},
+		{"query base language", "zh", "", "zh-CN"},
+		{"query underscore", "zh_cn", "", "zh-CN"},
+		{"unsupported query falls through", "fr", "zh-CN", "zh-CN"},
+		{"exact", "", "zh-CN", "zh-CN"},
+		{"base language", "", "zh-TW,zh;q=0.9", "zh-CN"},
+		{"q-values", "", "en;q=0.5, zh-CN;q=0.8", "zh-CN"},
+		{"ties keep client order", "", "en-GB, zh-CN", "en-US"},
+		{"first supported", "", "fr-FR, de;q=0.9, zh;q=0.5", "zh-CN"},
+		{"q=0 excluded", "", "zh-CN;q=0", ""},
+		{"wildcard", "", "*", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiateLocale(tt.lang, tt.acceptLanguage); got != tt.expected {
+				t.Errorf("got %q want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	tests := []struct {
+		locale   string
+		n        int
+		expected string
+	}{
+		{"en-US", 0, "0 endpoints"},
+		{"en-US", 1, "1 endpoint"},
+		{"en-US", 2, "2 endpoints"},
+		{"zh-CN", 1, "1 个接口"},
+		{"zh-CN", 2, "2 个接口"},
+		{"", 2, "Available Endpoints"},
+	}
+	for _, tt := range tests {
+		if got := (Printer{Locale: tt.locale}).Plural("dashboard.endpoints", tt.n); got != tt.expected {
+			t.Errorf("%s plural for %d: got %q want %q", tt.locale, tt.n, got, tt.expected)
+		}
+	}
+
+	if got := (Printer{Locale: "zh-CN"}).Sprintf("no.such.key"); got != "no.such.key" {
+		t.Errorf("missing key should fall back to the key, got %q", got)
+	}
+}
+
+func TestLocalizedResponses(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/hello", helloHandler)
+	mux.HandleFunc("/api/feature", featureHandler)
+	mux.HandleFunc("/", rootHandler)
+	handler := localize(mux)
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		locale   string
+		contains string
+	}{
+		{"hello default", "/api/hello", "", "", "Hello, World! 👋 (v2.5 with OpenTelemetry)"},
+		{"feature default", "/api/feature", "", "", "这是 v2.5 开发版"},
+		{"dashboard default", "/", "", "", "Available Endpoints"},
+		{"hello accept-language", "/api/hello", "zh-CN,zh;q=0.9", "zh-CN", "你好，世界！"},
+		{"hello query", "/api/hello?lang=zh-CN&name=Go", "en-US", "zh-CN", "你好，Go！"},
+		{"feature", "/api/feature?lang=en-US", "zh-CN", "en-US", "OpenTelemetry integration"},
+		{"dashboard", "/?lang=zh-CN", "", "zh-CN", "各路由请求速率"},
+		{"dashboard english", "/", "en", "en-US", "Request rate by route"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Accept-Language", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Content-Language"); got != tt.locale {
+				t.Errorf("unexpected Content-Language: got %q want %q", got, tt.locale)
+			}
+			if rr.Header().Get("Vary") != "Accept-Language" {
+				t.Errorf("missing Vary: Accept-Language")
+			}
+			if !strings.Contains(rr.Body.String(), tt.contains) {
+				t.Errorf("response does not contain %q: %s", tt.contains, rr.Body.String())
+			}
+			if strings.HasPrefix(tt.path, "/api/") {
+				var body struct {
+					Locale string `json:"locale"`
+				}
+				json.Unmarshal(rr.Body.Bytes(), &body)
+				if body.Locale != tt.locale {
+					t.Errorf("unexpected locale field: got %q want %q", body.Locale, tt.locale)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCLocale(t *testing.T) {
+	client := demov1.NewDemoServiceClient(dialTestServer(t))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "accept-language", "zh-CN")
+
+	var header metadata.MD
+	hello, err := client.Hello(ctx, &demov1.HelloRequest{Name: "gRPC"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(hello.Message, "你好，gRPC！") {
+		t.Errorf("unexpected greeting: %q", hello.Message)
+	}
+	if got := header.Get("content-language"); len(got) != 1 || got[0] != "zh-CN" {
+		t.Errorf("unexpected content-language header: %v", got)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Request priorities, lowest first. Critical requests bypass the limiter.
+const (
+	priorityLow = iota
+	priorityNormal
+	priorityHigh
+	priorityCritical
+)
+
+var priorityNames = []string{"low", "normal", "high", "critical"}
+
+func parsePriority(name string) (int, error) {
+	for i, n := range priorityNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority %q", name)
+}
+
+type waiter struct {
+	priority int
+	ready    chan bool // true when admitted, false when evicted by a higher priority request
+}
+
+// concurrencyLimiter bounds in-flight requests and queues the excess by priority
+type concurrencyLimiter struct {
+	cfg ConcurrencyConfig
+
+	mu       sync.Mutex
+	inFlight int
+	queue    [priorityCritical][]*waiter
+	queued   int
+
+	admitted atomic.Int64
+	shed     atomic.Int64
+}
+
+type ConcurrencyMetrics struct {
+	Enabled     bool  `json:"enabled"`
+	MaxInFlight int   `json:"maxInFlight"`
+	MaxQueue    int   `json:"maxQueue"`
+	InFlight    int   `json:"inFlight"`
+	QueueDepth  int   `json:"queueDepth"`
+	Admitted    int64 `json:"admitted"`
+	Shed        int64 `json:"shed"`
+}
+
+var limiter = newConcurrencyLimiter(ConcurrencyConfig{})
+
+func newConcurrencyLimiter(cfg ConcurrencyConfig) *concurrencyLimiter {
+	return &concurrencyLimiter{cfg: cfg}
+}
+
+// acquire admits the request or waits in the queue; it reports false when the request is shed
+func (l *concurrencyLimiter) acquire(ctx context.Context, priority int) bool {
+	l.mu.Lock()
+	if priority >= priorityCritical || l.inFlight < l.cfg.MaxInFlight && l.queued == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		l.admitted.Add(1)
+		return true
+	}
+
+	if l.queued >= l.cfg.MaxQueue && !l.evictLowerThan(priority) {
+		l.mu.Unlock()
+		return false
+	}
+	w := &waiter{priority: priority, ready: make(chan bool, 1)}
+	l.queue[priority] = append(l.queue[priority], w)
+	l.queued++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case ok := <-w.ready:
+		if ok {
+			l.admitted.Add(1)
+		}
+		return ok
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.remove(w) {
+		// Admitted or evicted while we were timing out
+		ok := <-w.ready
+		if ok {
+			l.admitted.Add(1)
+		}
+		return ok
+	}
+	return false
+}
+
+// release frees a slot and hands it to the oldest waiter of the highest priority
+func (l *concurrencyLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	for p := priorityCritical - 1; p >= 0; p-- {
+		if len(l.queue[p]) > 0 && l.inFlight < l.cfg.MaxInFlight {
+			w := l.queue[p][0]
+			l.queue[p] = l.queue[p][1:]
+			l.queued--
+			l.inFlight++
+			w.ready <- true
+			return
+		}
+	}
+}
+
+// evictLowerThan drops the newest waiter with a priority below p to make room in the queue
+func (l *concurrencyLimiter) evictLowerThan(p int) bool {
+	for q := 0; q < p; q++ {
+		if n := len(l.queue[q]); n > 0 {
+			w := l.queue[q][n-1]
+			l.queue[q] = l.queue[q][:n-1]
+			l.queued--
+			w.ready <- false
+			return true
+		}
+	}
+	return false
+}
+
+func (l *concurrencyLimiter) remove(w *waiter) bool {
+	q := l.queue[w.priority]
+	for i, x := range q {
+		if x == w {
+			l.queue[w.priority] = append(q[:i:i], q[i+1:]...)
+			l.queued--
+			return true
+		}
+	}
+	return false
+}
+
+func (l *concurrencyLimiter) metrics() ConcurrencyMetrics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ConcurrencyMetrics{
+		Enabled:     l.cfg.MaxInFlight > 0,
+		MaxInFlight: l.cfg.MaxInFlight,
+		MaxQueue:    l.cfg.MaxQueue,
+		InFlight:    l.inFlight,
+		QueueDepth:  l.queued,
+		Admitted:    l.admitted.Load(),
+		Shed:        l.shed.Load(),
+	}
+}
+
+// isProbe reports whether path is a liveness or readiness probe, which
+// admission control must never turn away
+func isProbe(path string) bool {
+	return path == "/health" || path == "/ready"
+}
+
+// routePriority returns the configured priority for a path; probes are always critical
+func (l *concurrencyLimiter) routePriority(path string) int {
+	if isProbe(path) {
+		return priorityCritical
+	}
+	if name, ok := l.cfg.RoutePriorities[path]; ok {
+		if p, err := parsePriority(name); err == nil {
+			return p
+		}
+	}
+	return priorityNormal
+}
+
+// limitConcurrency sheds requests with 503 and Retry-After once the limiter is saturated
+func limitConcurrency(l *concurrencyLimiter, next http.Handler) http.Handler {
+	if l.cfg.MaxInFlight <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// WebSockets and event streams are long-lived and bounded separately
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || r.URL.Path == "/api/events" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !l.admit(r.Context(), r.URL.Path) {
+			retryAfter := int(math.Ceil(l.cfg.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeProblem(w, r, http.StatusServiceUnavailable, "server is overloaded, retry later")
+			return
+		}
+		defer l.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// admit waits for a slot at the priority of path and reports whether one was
+// granted. The caller must release a granted slot.
+func (l *concurrencyLimiter) admit(ctx context.Context, path string) bool {
+	priority := l.routePriority(path)
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("request.priority", priorityNames[priority]))
+
+	if !l.acquire(ctx, priority) {
+		l.shed.Add(1)
+		span.AddEvent("load.shed")
+		log.Printf("[WARN] Request shed, path: %s, priority: %s, traceId: %s",
+			path, priorityNames[priority], getTraceID(ctx))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimitConcurrencyShedsWithRetryAfter(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyConfig{
+		MaxInFlight:  1,
+		MaxQueue:     1,
+		QueueTimeout: 50 * time.Millisecond,
+		RetryAfter:   2 * time.Second,
+	})
+
+	block := make(chan struct{})
+	handler := limitConcurrency(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/slow" {
+			<-block
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/slow", nil))
+	}()
+	for l.metrics().InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Queued request times out and is shed
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/hello", nil))
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+	}
+	if ra := rr.Header().Get("Retry-After"); ra != "2" {
+		t.Errorf("unexpected Retry-After: got %q want %q", ra, "2")
+	}
+
+	// Probes are never shed
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("probe returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	close(block)
+	wg.Wait()
+
+	m := l.metrics()
+	if m.Shed != 1 || m.InFlight != 0 || m.QueueDepth != 0 {
+		t.Errorf("unexpected limiter metrics: %+v", m)
+	}
+}
+
+func TestConcurrencyLimiterPriorityEviction(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyConfig{
+		MaxInFlight:     1,
+		MaxQueue:        1,
+		QueueTimeout:    time.Second,
+		RoutePriorities: map[string]string{"/api/random": "low", "/api/hello": "high"},
+	})
+	ctx := httptest.NewRequest("GET", "/", nil).Context()
+
+	if !l.acquire(ctx, priorityNormal) {
+		t.Fatal("first request should be admitted")
+	}
+
+	low := make(chan bool, 1)
+	go func() { low <- l.acquire(ctx, l.routePriority("/api/random")) }()
+	for l.metrics().QueueDepth == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	high := make(chan bool, 1)
+	go func() { high <- l.acquire(ctx, l.routePriority("/api/hello")) }()
+
+	if ok := <-low; ok {
+		t.Error("low priority waiter should be evicted by a high priority request")
+	}
+	l.release()
+	if ok := <-high; !ok {
+		t.Error("high priority waiter should be admitted after release")
+	}
+	l.release()
+}
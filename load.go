@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const defaultLoadRoutes = "/api/hello=4,/api/status=2,/api/time=2,/api/random=2,/api/info=1"
+
+// subBucketBits splits every power-of-two range of the histogram into 64 linear
+// buckets, so recorded values are accurate to within about 1.6%
+const subBucketBits = 7
+
+// latencyHistogram is a log-linear histogram of microseconds, in the spirit of HdrHistogram
+type latencyHistogram struct {
+	counts        []int64
+	total         int64
+	sum, min, max int64
+}
+
+func histogramIndex(v int64) int {
+	if v < 1<<subBucketBits {
+		return int(v)
+	}
+	exp := bits.Len64(uint64(v)) - subBucketBits
+	sub := v >> exp
+	return 1<<subBucketBits + (exp-1)<<(subBucketBits-1) + int(sub-1<<(subBucketBits-1))
+}
+
+// histogramValue is the highest value that lands in bucket i
+func histogramValue(i int) int64 {
+	if i < 1<<subBucketBits {
+		return int64(i)
+	}
+	k := i - 1<<subBucketBits
+	exp := k>>(subBucketBits-1) + 1
+	sub := int64(k%(1<<(subBucketBits-1)) + 1<<(subBucketBits-1))
+	return (sub+1)<<exp - 1
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	v := d.Microseconds()
+	if v < 0 {
+		v = 0
+	}
+	i := histogramIndex(v)
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	if h.total == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.total++
+	h.sum += v
+}
+
+// percentile returns the value below which p percent of the recordings fall, in microseconds
+func (h *latencyHistogram) percentile(p float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(p / 100 * float64(h.total)))
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= target {
+			if v := histogramValue(i); v < h.max {
+				return v
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// LatencySummary is in milliseconds
+type LatencySummary struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+func (h *latencyHistogram) summary() LatencySummary {
+	ms := func(us int64) float64 { return float64(us) / 1000 }
+	s := LatencySummary{
+		Min:  ms(h.min),
+		P50:  ms(h.percentile(50)),
+		P90:  ms(h.percentile(90)),
+		P95:  ms(h.percentile(95)),
+		P99:  ms(h.percentile(99)),
+		P999: ms(h.percentile(99.9)),
+		Max:  ms(h.max),
+	}
+	if h.total > 0 {
+		s.Mean = ms(h.sum) / float64(h.total)
+	}
+	return s
+}
+
+// writeDistribution prints the percentile ladder in HdrHistogram's text layout
+func (h *latencyHistogram) writeDistribution(w io.Writer) {
+	fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value(ms)", "Percentile", "TotalCount", "1/(1-Percentile)")
+	for _, p := range []float64{0, 50, 75, 87.5, 93.75, 96.875, 98.4375, 99.21875, 99.9, 99.99, 100} {
+		v := h.percentile(p)
+		var count int64
+		for i := 0; i <= histogramIndex(v) && i < len(h.counts); i++ {
+			count += h.counts[i]
+		}
+		inverse := "inf"
+		if p < 100 {
+			inverse = fmt.Sprintf("%.2f", 100/(100-p))
+		}
+		fmt.Fprintf(w, "%12.3f %14.6f %10d %14s\n", float64(v)/1000, p/100, count, inverse)
+	}
+	mean := 0.0
+	if h.total > 0 {
+		mean = float64(h.sum) / float64(h.total) / 1000
+	}
+	fmt.Fprintf(w, "#[Mean    = %12.3f, Max        = %12.3f]\n", mean, float64(h.max)/1000)
+	fmt.Fprintf(w, "#[Total count = %8d]\n", h.total)
+}
+
+// LoadRoute is one entry of the weighted route mix
+type LoadRoute struct {
+	Path   string `json:"path"`
+	Weight int    `json:"weight"`
+}
+
+// parseLoadRoutes reads "path=weight,path" lists; a missing weight means 1.
+// Only a trailing integer counts as a weight, so query strings keep their "=".
+func parseLoadRoutes(s string) ([]LoadRoute, error) {
+	var routes []LoadRoute
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route := LoadRoute{Path: entry, Weight: 1}
+		if i := strings.LastIndex(entry, "="); i > 0 {
+			if w, err := strconv.Atoi(entry[i+1:]); err == nil {
+				route.Path, route.Weight = entry[:i], w
+			}
+		}
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("route %q must start with /", route.Path)
+		}
+		if route.Weight < 1 {
+			return nil, fmt.Errorf("route %q needs a positive weight", route.Path)
+		}
+		routes = append(routes, route)
+	}
+	if len(routes) == 0 {
+		return nil, errors.New("no routes given")
+	}
+	return routes, nil
+}
+
+// headerFlag collects repeated -H "Name: value" flags
+type headerFlag http.Header
+
+func (h headerFlag) String() string { return "" }
+
+func (h headerFlag) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q must be Name: value", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
+
+// LoadOptions controls a run of the load subcommand. RPS of 0 runs Concurrency
+// workers back to back; otherwise Concurrency caps the requests in flight.
+type LoadOptions struct {
+	Target       string
+	RPS          float64
+	Concurrency  int
+	Duration     time.Duration
+	RampUp       time.Duration
+	Timeout      time.Duration
+	Routes       []LoadRoute
+	Headers      http.Header
+	ReportFile   string
+	MaxErrorRate float64
+	MaxP99       time.Duration
+	MaxDropped   int64 // negative for no limit
+}
+
+type RouteReport struct {
+	Path     string         `json:"path"`
+	Weight   int            `json:"weight"`
+	Requests int64          `json:"requests"`
+	Errors   int64          `json:"errors"`
+	Latency  LatencySummary `json:"latencyMs"`
+}
+
+// LoadReport is written to -report for pipeline gates. Passed is false when a
+// -max-* threshold was exceeded, with the reasons in Failures.
+type LoadReport struct {
+	Target          string           `json:"target"`
+	Mode            string           `json:"mode"`
+	RPS             float64          `json:"rps,omitempty"`
+	Concurrency     int              `json:"concurrency"`
+	DurationSeconds float64          `json:"durationSeconds"`
+	Requests        int64            `json:"requests"`
+	Errors          int64            `json:"errors"`
+	ErrorRate       float64          `json:"errorRate"`
+	Dropped         int64            `json:"dropped"` // not sent because Concurrency requests were in flight
+	Throughput      float64          `json:"throughputRps"`
+	Latency         LatencySummary   `json:"latencyMs"`
+	Statuses        map[string]int64 `json:"statuses"`
+	Routes          []RouteReport    `json:"routes"`
+	Passed          bool             `json:"passed"`
+	Failures        []string         `json:"failures,omitempty"`
+	Timestamp       string           `json:"timestamp"`
+}
+
+type loadRun struct {
+	opts    LoadOptions
+	client  *http.Client
+	weights []int // cumulative
+	dropped atomic.Int64
+
+	mu       sync.Mutex
+	total    latencyHistogram
+	routes   []latencyHistogram
+	requests []int64
+	errors   []int64
+	statuses map[string]int64
+}
+
+func newLoadRun(opts LoadOptions) *loadRun {
+	l := &loadRun{
+		opts: opts,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency},
+		},
+		routes:   make([]latencyHistogram, len(opts.Routes)),
+		requests: make([]int64, len(opts.Routes)),
+		errors:   make([]int64, len(opts.Routes)),
+		statuses: make(map[string]int64),
+	}
+	sum := 0
+	for _, r := range opts.Routes {
+		sum += r.Weight
+		l.weights = append(l.weights, sum)
+	}
+	return l
+}
+
+func (l *loadRun) pick(rng *rand.Rand) int {
+	n := rng.Intn(l.weights[len(l.weights)-1])
+	return sort.SearchInts(l.weights, n+1)
+}
+
+// do sends one request to route i. Requests cut short by an interrupt are not recorded.
+func (l *loadRun) do(ctx context.Context, i int) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(l.opts.Target, "/")+l.opts.Routes[i].Path, nil)
+	if err != nil {
+		return
+	}
+	for name, values := range l.opts.Headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("User-Agent", "demo-app-load/"+Version)
+
+	start := time.Now()
+	resp, err := l.client.Do(req)
+	var status string
+	failed := true
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		status, failed = strconv.Itoa(resp.StatusCode), resp.StatusCode >= 400
+	} else if ctx.Err() != nil {
+		return
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		status = "timeout"
+	} else {
+		status = "connection_error"
+	}
+	latency := time.Since(start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total.record(latency)
+	l.routes[i].record(latency)
+	l.requests[i]++
+	if failed {
+		l.errors[i]++
+	}
+	l.statuses[status]++
+}
+
+// scheduled is how many requests should have been sent t into an open-loop run,
+// with the rate rising linearly over the ramp-up
+func (l *loadRun) scheduled(t time.Duration) int64 {
+	secs, ramp := t.Seconds(), l.opts.RampUp.Seconds()
+	if secs < ramp {
+		return int64(l.opts.RPS * secs * secs / (2 * ramp))
+	}
+	return int64(l.opts.RPS * (ramp/2 + secs - ramp))
+}
+
+// run drives the target until the duration is over or ctx is done, then waits
+// for requests in flight
+func (l *loadRun) run(ctx context.Context) time.Duration {
+	stop, cancel := context.WithTimeout(ctx, l.opts.Duration)
+	defer cancel()
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	if l.opts.RPS > 0 {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		inFlight := make(chan struct{}, l.opts.Concurrency)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		var sent int64
+		for stop.Err() == nil {
+			for due := l.scheduled(time.Since(start)); sent < due; sent++ {
+				select {
+				case inFlight <- struct{}{}:
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						l.do(ctx, i)
+						<-inFlight
+					}(l.pick(rng))
+				default:
+					l.dropped.Add(1)
+				}
+			}
+			select {
+			case <-stop.Done():
+			case <-ticker.C:
+			}
+		}
+	} else {
+		for w := 0; w < l.opts.Concurrency; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				// Workers join evenly over the ramp-up
+				delay := time.NewTimer(l.opts.RampUp * time.Duration(w) / time.Duration(l.opts.Concurrency))
+				defer delay.Stop()
+				select {
+				case <-stop.Done():
+					return
+				case <-delay.C:
+				}
+				rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w)))
+				for stop.Err() == nil {
+					l.do(ctx, l.pick(rng))
+				}
+			}(w)
+		}
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+func (l *loadRun) report(elapsed time.Duration) LoadReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := LoadReport{
+		Target:          l.opts.Target,
+		Mode:            "concurrency",
+		Concurrency:     l.opts.Concurrency,
+		DurationSeconds: elapsed.Seconds(),
+		Requests:        l.total.total,
+		Dropped:         l.dropped.Load(),
+		Latency:         l.total.summary(),
+		Statuses:        make(map[string]int64, len(l.statuses)),
+		Passed:          true,
+		Timestamp:       time.Now().UTC().Format(time.RFC3339),
+	}
+	if l.opts.RPS > 0 {
+		r.Mode, r.RPS = "rps", l.opts.RPS
+	}
+	for status, n := range l.statuses {
+		r.Statuses[status] = n
+	}
+	for i, route := range l.opts.Routes {
+		r.Errors += l.errors[i]
+		r.Routes = append(r.Routes, RouteReport{
+			Path:     route.Path,
+			Weight:   route.Weight,
+			Requests: l.requests[i],
+			Errors:   l.errors[i],
+			Latency:  l.routes[i].summary(),
+		})
+	}
+	if r.Requests > 0 {
+		r.ErrorRate = float64(r.Errors) / float64(r.Requests)
+	}
+	if elapsed > 0 {
+		r.Throughput = float64(r.Requests) / elapsed.Seconds()
+	}
+
+	if r.Requests == 0 {
+		r.Failures = append(r.Failures, "no requests completed")
+	}
+	if r.ErrorRate > l.opts.MaxErrorRate {
+		r.Failures = append(r.Failures, fmt.Sprintf("error rate %.4f exceeds %.4f", r.ErrorRate, l.opts.MaxErrorRate))
+	}
+	if limit := float64(l.opts.MaxP99.Microseconds()) / 1000; limit > 0 && r.Latency.P99 > limit {
+		r.Failures = append(r.Failures, fmt.Sprintf("p99 %.1fms exceeds %.1fms", r.Latency.P99, limit))
+	}
+	// Dropped requests were never measured, so the rate the gates saw is lower than asked for
+	if l.opts.MaxDropped >= 0 && r.Dropped > l.opts.MaxDropped {
+		r.Failures = append(r.Failures, fmt.Sprintf("%d requests dropped, more than %d", r.Dropped, l.opts.MaxDropped))
+	}
+	r.Passed = len(r.Failures) == 0
+	return r
+}
+
+// writeLoadReport prints the human-readable summary
+func writeLoadReport(w io.Writer, r LoadReport, h *latencyHistogram) {
+	fmt.Fprintf(w, "Target %s, %s mode, %d requests in %.1fs (%.1f req/s), %d dropped\n\n",
+		r.Target, r.Mode, r.Requests, r.DurationSeconds, r.Throughput, r.Dropped)
+	h.writeDistribution(w)
+
+	fmt.Fprintf(w, "\n%-32s %8s %8s %10s %10s %10s\n", "Route", "Requests", "Errors", "p50(ms)", "p99(ms)", "max(ms)")
+	for _, route := range r.Routes {
+		fmt.Fprintf(w, "%-32s %8d %8d %10.1f %10.1f %10.1f\n",
+			route.Path, route.Requests, route.Errors, route.Latency.P50, route.Latency.P99, route.Latency.Max)
+	}
+
+	statuses := make([]string, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	fmt.Fprintf(w, "\nStatus breakdown:\n")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %-18s %8d\n", status, r.Statuses[status])
+	}
+	fmt.Fprintf(w, "\nError rate: %.2f%%\n", r.ErrorRate*100)
+	for _, f := range r.Failures {
+		fmt.Fprintf(w, "FAILED: %s\n", f)
+	}
+}
+
+// parseLoadFlags reads the load subcommand flags, defaulting the target from cfg
+func parseLoadFlags(args []string, cfg *Config, output io.Writer) (LoadOptions, error) {
+	opts := LoadOptions{Headers: http.Header{}}
+	var routes string
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.Target, "target", "http://localhost:"+cfg.Port, "base URL to load")
+	fs.Float64Var(&opts.RPS, "rps", 0, "requests per second, 0 to run -concurrency workers back to back")
+	fs.IntVar(&opts.Concurrency, "concurrency", 10, "workers, or the most requests in flight with -rps")
+	fs.DurationVar(&opts.Duration, "duration", 30*time.Second, "how long to run")
+	fs.DurationVar(&opts.RampUp, "ramp-up", 0, "time to reach the full rate or worker count")
+	fs.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "per-request timeout")
+	fs.StringVar(&routes, "routes", defaultLoadRoutes, "weighted route mix, path=weight,...")
+	fs.Var(headerFlag(opts.Headers), "H", "extra request header \"Name: value\", repeatable")
+	fs.StringVar(&opts.ReportFile, "report", "", "write a JSON report to this file")
+	fs.Float64Var(&opts.MaxErrorRate, "max-error-rate", 1, "fail when the error rate exceeds this fraction")
+	fs.DurationVar(&opts.MaxP99, "max-p99", 0, "fail when p99 latency exceeds this, 0 for no limit")
+	fs.Int64Var(&opts.MaxDropped, "max-dropped", 0, "fail when more requests than this are dropped with -rps, negative for no limit")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	var err error
+	if opts.Routes, err = parseLoadRoutes(routes); err != nil {
+		return opts, err
+	}
+	switch {
+	case fs.NArg() > 0:
+		return opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	case !strings.HasPrefix(opts.Target, "http://") && !strings.HasPrefix(opts.Target, "https://"):
+		return opts, fmt.Errorf("-target must be an http or https URL, got %q", opts.Target)
+	case opts.RPS < 0, opts.RampUp < 0, opts.MaxP99 < 0:
+		return opts, errors.New("-rps, -ramp-up and -max-p99 must not be negative")
+	case opts.Concurrency < 1, opts.Duration <= 0, opts.Timeout <= 0:
+		return opts, errors.New("-concurrency, -duration and -timeout must be positive")
+	case opts.RampUp > opts.Duration:
+		return opts, errors.New("-ramp-up must not be longer than -duration")
+	}
+	return opts, nil
+}
+
+// runLoad implements `demo-app load`: exit status 1 when a gate fails
+func runLoad(args []string) int {
+	cfg, err := readConfig()
+	if err != nil {
+		log.Printf("[ERROR] Invalid configuration: %v", err)
+		return 1
+	}
+	opts, err := parseLoadFlags(args, cfg, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("[INFO] Load test, target: %s, rps: %g, concurrency: %d, duration: %s, ramp-up: %s",
+		opts.Target, opts.RPS, opts.Concurrency, opts.Duration, opts.RampUp)
+	l := newLoadRun(opts)
+	report := l.report(l.run(ctx))
+	writeLoadReport(os.Stdout, report, &l.total)
+
+	if opts.ReportFile != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if err := os.WriteFile(opts.ReportFile, append(data, '\n'), 0o644); err != nil {
+			log.Printf("[ERROR] Failed to write report: %v", err)
+			return 1
+		}
+	}
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
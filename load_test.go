@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 129, 255, 256, 1000, 12345, 999999, 3600000000} {
+		i := histogramIndex(v)
+		if hi := histogramValue(i); hi < v || float64(hi-v) > float64(v)/64+1 {
+			t.Errorf("%d lands in bucket %d with highest value %d", v, i, hi)
+		}
+		if i > 0 && histogramValue(i-1) >= v {
+			t.Errorf("%d should not fit in the bucket below %d", v, i)
+		}
+	}
+
+	var h latencyHistogram
+	for ms := 1; ms <= 1000; ms++ {
+		h.record(time.Duration(ms) * time.Millisecond)
+	}
+	tests := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 1},
+		{50, 500},
+		{90, 900},
+		{99, 990},
+		{100, 1000},
+	}
+	for _, tt := range tests {
+		got := float64(h.percentile(tt.p)) / 1000
+		if math.Abs(got-tt.expected) > tt.expected/64 {
+			t.Errorf("p%g: got %.3fms want about %.0fms", tt.p, got, tt.expected)
+		}
+	}
+	if s := h.summary(); s.Min != 1 || s.Max != 1000 || s.Mean != 500.5 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+}
+
+func TestParseLoadRoutes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []LoadRoute
+		err      string
+	}{
+		{"weights", "/a=3, /b", []LoadRoute{{"/a", 3}, {"/b", 1}}, ""},
+		{"query string", "/api/echo?message=hi=2,/api/hello?name=x", []LoadRoute{{"/api/echo?message=hi", 2}, {"/api/hello?name=x", 1}}, ""},
+		{"relative", "api/hello", nil, "must start with /"},
+		{"zero weight", "/a=0", nil, "positive weight"},
+		{"empty", " , ", nil, "no routes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := parseLoadRoutes(tt.input)
+			checkErr(t, err, tt.err)
+			if tt.err != "" {
+				return
+			}
+			if err != nil || len(routes) != len(tt.expected) {
+				t.Fatalf("got %v, %v", routes, err)
+			}
+			for i := range routes {
+				if routes[i] != tt.expected[i] {
+					t.Errorf("route %d: got %+v want %+v", i, routes[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseLoadFlags(t *testing.T) {
+	cfg := loadConfig()
+	opts, err := parseLoadFlags([]string{"-rps=50", "-H", "X-API-Key: secret", "-routes=/api/hello"}, cfg, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Target != "http://localhost:"+cfg.Port || opts.Headers.Get("X-API-Key") != "secret" || len(opts.Routes) != 1 {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	for _, args := range [][]string{
+		{"-target=localhost:8000"},
+		{"-concurrency=0"},
+		{"-ramp-up=1m", "-duration=10s"},
+		{"-H", "no colon"},
+	} {
+		if _, err := parseLoadFlags(args, cfg, io.Discard); err == nil {
+			t.Errorf("%v should be rejected", args)
+		}
+	}
+}
+
+func TestLoadRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "yes" {
+			t.Errorf("header not sent")
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		rps  float64
+	}{
+		{"rps", 200},
+		{"concurrency", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLoadRun(LoadOptions{
+				Target:       srv.URL,
+				RPS:          tt.rps,
+				Concurrency:  4,
+				Duration:     300 * time.Millisecond,
+				RampUp:       100 * time.Millisecond,
+				Timeout:      time.Second,
+				Routes:       []LoadRoute{{"/ok", 3}, {"/fail", 1}},
+				Headers:      http.Header{"X-Test": {"yes"}},
+				MaxErrorRate: 0.1,
+				MaxDropped:   -1,
+			})
+			r := l.report(l.run(context.Background()))
+
+			if r.Requests == 0 || r.Statuses["200"]+r.Statuses["503"] != r.Requests {
+				t.Fatalf("unexpected statuses: %+v", r.Statuses)
+			}
+			if r.Errors != r.Statuses["503"] || r.Routes[1].Errors != r.Routes[1].Requests {
+				t.Errorf("503s should be counted as errors of /fail: %+v", r)
+			}
+			if r.ErrorRate < 0.1 || r.ErrorRate > 0.45 {
+				t.Errorf("error rate %.2f does not follow the 3:1 mix", r.ErrorRate)
+			}
+			if r.Passed || len(r.Failures) != 1 || !strings.Contains(r.Failures[0], "error rate") {
+				t.Errorf("error rate gate should fail: %v", r.Failures)
+			}
+			if tt.rps > 0 && (r.Requests < 20 || r.Requests > 60) {
+				// 200/s for 300ms less the ramp-up is about 50 requests
+				t.Errorf("unexpected request count at %g rps: %d", tt.rps, r.Requests)
+			}
+		})
+	}
+}
+
+func TestLoadReportDropped(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxDropped int64
+		dropped    int64
+		passed     bool
+	}{
+		{"none dropped", 0, 0, true},
+		{"dropped", 0, 3, false},
+		{"within limit", 5, 3, true},
+		{"no limit", -1, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLoadRun(LoadOptions{Target: "http://localhost", Routes: []LoadRoute{{"/ok", 1}}, MaxErrorRate: 1, MaxDropped: tt.maxDropped})
+			l.total.record(time.Millisecond)
+			l.routes[0].record(time.Millisecond)
+			l.requests[0]++
+			l.dropped.Add(tt.dropped)
+			r := l.report(time.Second)
+			if r.Passed != tt.passed {
+				t.Errorf("got passed %t want %t: %v", r.Passed, tt.passed, r.Failures)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// Log levels understood by the "[LEVEL]" prefix convention used in log messages
+const (
+	levelDebug int32 = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+var logLevel atomic.Int32
+
+func init() {
+	logLevel.Store(levelInfo)
+}
+
+// parseLogLevel converts a level name into its numeric value
+func parseLogLevel(name string) (int32, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return int32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", name)
+}
+
+// setLogLevel changes the minimum level written to the log
+func setLogLevel(name string) error {
+	lvl, err := parseLogLevel(name)
+	if err != nil {
+		return err
+	}
+	logLevel.Store(lvl)
+	return nil
+}
+
+func currentLogLevel() string {
+	return levelNames[logLevel.Load()]
+}
+
+// levelWriter drops log lines tagged with a level below the current minimum.
+// Lines without a "[LEVEL]" tag are always written.
+type levelWriter struct {
+	out io.Writer
+}
+
+var levelTags = [][]byte{[]byte("[DEBUG]"), []byte("[INFO]"), []byte("[WARN]"), []byte("[ERROR]")}
+
+func (lw *levelWriter) Write(p []byte) (int, error) {
+	min := logLevel.Load()
+	for lvl, tag := range levelTags {
+		if bytes.Contains(p, tag) {
+			if int32(lvl) < min {
+				return len(p), nil
+			}
+			break
+		}
+	}
+	return lw.out.Write(p)
+}
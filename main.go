@@ -8,8 +8,10 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
@@ -22,6 +24,7 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -37,6 +40,13 @@ type Response struct {
 	Timestamp string `json:"timestamp"`
 }
 
+// ReadinessResponse reports whether every registered health check passes
+type ReadinessResponse struct {
+	Status    string        `json:"status"` // ready or unready
+	Checks    []HealthCheck `json:"checks"`
+	Timestamp string        `json:"timestamp"`
+}
+
 type VersionInfo struct {
 	Version   string `json:"version"`
 	BuildTime string `json:"buildTime"`
@@ -45,6 +55,7 @@ type VersionInfo struct {
 
 type HelloResponse struct {
 	Message   string `json:"message"`
+	Locale    string `json:"locale,omitempty"`
 	Timestamp string `json:"timestamp"`
 	TraceID   string `json:"traceId,omitempty"`
 }
@@ -61,15 +72,27 @@ type FeatureResponse struct {
 	Feature     string `json:"feature"`
 	Description string `json:"description"`
 	Version     string `json:"version"`
+	Locale      string `json:"locale,omitempty"`
 	Timestamp   string `json:"timestamp"`
 }
 
 type MetricsResponse struct {
-	RequestCount int64  `json:"requestCount"`
-	MemoryUsage  string `json:"memoryUsage"`
-	GoRoutines   int    `json:"goRoutines"`
-	Uptime       string `json:"uptime"`
-	Timestamp    string `json:"timestamp"`
+	RequestCount int64                `json:"requestCount"`
+	MemoryUsage  string               `json:"memoryUsage"`
+	GoRoutines   int                  `json:"goRoutines"`
+	Uptime       string               `json:"uptime"`
+	Process      *ProcessMetrics      `json:"process,omitempty"`
+	GC           GCMetrics            `json:"gc"`
+	Container    *ContainerMetrics    `json:"container,omitempty"`
+	Runtime      RuntimeMetrics       `json:"runtime"`
+	Stress       []StressSession      `json:"stress,omitempty"`
+	Concurrency  ConcurrencyMetrics   `json:"concurrency"`
+	RateLimit    RateLimitMetrics     `json:"rateLimit"`
+	Authz        AuthorizationMetrics `json:"authorization"`
+	Compression  CompressionMetrics   `json:"compression"`
+	Protocols    ProtocolMetrics      `json:"protocols"`
+	WebSockets   WebSocketMetrics     `json:"webSockets"`
+	Timestamp    string               `json:"timestamp"`
 }
 
 type EchoResponse struct {
@@ -77,6 +100,9 @@ type EchoResponse struct {
 	Headers   map[string]string `json:"headers"`
 	Method    string            `json:"method"`
 	Path      string            `json:"path"`
+	Subject   string            `json:"subject,omitempty"`
+	Protocol  string            `json:"protocol"`
+	TLS       *TLSInfo          `json:"tls,omitempty"`
 	Timestamp string            `json:"timestamp"`
 	TraceID   string            `json:"traceId,omitempty"`
 }
@@ -85,6 +111,7 @@ type InfoResponse struct {
 	AppName     string `json:"appName"`
 	Version     string `json:"version"`
 	Description string `json:"description"`
+	Locale      string `json:"locale,omitempty"`
 	Author      string `json:"author"`
 	GoVersion   string `json:"goVersion"`
 	OS          string `json:"os"`
@@ -107,6 +134,7 @@ type RandomResponse struct {
 	UUID        string `json:"uuid"`
 	Color       string `json:"color"`
 	Quote       string `json:"quote"`
+	Locale      string `json:"locale,omitempty"`
 	LuckyNumber int    `json:"luckyNumber"`
 	Dice        []int  `json:"dice"`
 	Timestamp   string `json:"timestamp"`
@@ -118,103 +146,261 @@ var requestCount int64
 
 
 // initTracer initializes OpenTelemetry tracer
-func initTracer(ctx context.Context) (*sdktrace.TracerProvider, error) {
-	// Get OTLP endpoint from environment, default to Jaeger
-	otlpEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if otlpEndpoint == "" {
-		otlpEndpoint = "jaeger:4318" // Default to Jaeger in Docker network
+func initTracer(ctx context.Context, cfg *Config) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	tp, err := newTracerProvider(exporter, cfg.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	// Recent spans are also kept in memory for /admin/traces
+	if cfg.Admin.Enabled {
+		tp.RegisterSpanProcessor(traces)
 	}
 
-	// Create OTLP HTTP exporter
+	// Set global TracerProvider
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tp, nil
+}
+
+// newExporter creates the OTLP HTTP exporter for cfg.OTLPEndpoint
+func newExporter(ctx context.Context, cfg *Config) (sdktrace.SpanExporter, error) {
 	exporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint(otlpEndpoint),
+		otlptracehttp.WithEndpoint(cfg.OTLPEndpoint),
 		otlptracehttp.WithInsecure(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
 	}
+	return exporter, nil
+}
 
-	// Get service name from environment
-	serviceName := os.Getenv("OTEL_SERVICE_NAME")
-	if serviceName == "" {
-		serviceName = "demo-app"
+// newTracerProvider builds a provider whose resource identifies serviceName.
+// The topology simulator uses one per virtual service.
+func newTracerProvider(exporter sdktrace.SpanExporter, serviceName string, attrs ...attribute.KeyValue) (*sdktrace.TracerProvider, error) {
+	res, err := newResource(serviceName, attrs...)
+	if err != nil {
+		return nil, err
 	}
 
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	), nil
+}
+
+// newResource describes serviceName with the running version and environment
+func newResource(serviceName string, attrs ...attribute.KeyValue) (*resource.Resource, error) {
 	// Create resource with service information
 	res, err := resource.Merge(
 		resource.Default(),
 		resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion(Version),
-			attribute.String("environment", os.Getenv("APP_ENV")),
+			append([]attribute.KeyValue{
+				semconv.ServiceName(serviceName),
+				semconv.ServiceVersion(Version),
+				attribute.String("environment", os.Getenv("APP_ENV")),
+			}, attrs...)...,
 		),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
+	return res, nil
+}
 
-	// Create TracerProvider
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-	)
-
-	// Set global TracerProvider
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+// newAppMux registers every public endpoint. The smoke subcommand checks each of them.
+func newAppMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/ready", readyHandler)
+	mux.HandleFunc("/version", versionHandler)
+	mux.HandleFunc("/api/hello", helloHandler)
+	mux.HandleFunc("/api/status", statusHandler)
+	mux.HandleFunc("/api/feature", featureHandler)
+	mux.HandleFunc("/api/metrics", metricsHandler)
+	mux.HandleFunc("/api/events", eventsHandler)
+	mux.HandleFunc("/api/echo", echoHandler)
+	mux.HandleFunc("/api/info", infoHandler)
+	mux.HandleFunc("/api/time", timeHandler)
+	mux.HandleFunc("/api/random", randomHandler)
+	mux.HandleFunc("/api/proxy", proxyHandler)
+	mux.HandleFunc("/api/chain", chainHandler)
+	mux.HandleFunc("/csp-report", cspReportHandler)
+	mux.HandleFunc("/ws/echo", wsEchoHandler)
+	mux.HandleFunc("/ws/broadcast", wsBroadcastHandler)
+	mux.Handle("/static/", dashboardAssets())
+	mux.HandleFunc("/", rootHandler)
+	return mux
+}
 
-	return tp, nil
+// subcommands run instead of the server when named as the first argument
+var subcommands = map[string]func(args []string) int{
+	"generate":    runGenerate,
+	"load":        runLoad,
+	"smoke":       runSmoke,
+	"healthcheck": runHealthcheck,
 }
 
 func main() {
 	ctx := context.Background()
+	log.SetOutput(&levelWriter{out: os.Stderr})
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+	cfg, err := readConfig()
+	if err == nil {
+		err = applyConfig(cfg)
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	limiter = newConcurrencyLimiter(cfg.Concurrency)
+	watchSignals()
 
 	// Initialize OpenTelemetry
-	tp, err := initTracer(ctx)
+	tp, err := initTracer(ctx, cfg)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize tracer: %v", err)
 	} else {
-		defer func() {
-			if err := tp.Shutdown(ctx); err != nil {
-				log.Printf("Error shutting down tracer: %v", err)
-			}
-		}()
 		tracer = otel.Tracer("demo-app")
 		log.Println("OpenTelemetry tracer initialized successfully")
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
+	// Listeners stopped on SIGINT/SIGTERM, the public one is added last
+	var servers []*http.Server
+	var grpcSrv *grpc.Server
+
+	// Operational endpoints live on their own listener, never on the public port
+	if cfg.Admin.Enabled {
+		if adminSrv, err := startAdminServer(cfg); err != nil {
+			log.Printf("[ERROR] Admin API disabled: %v", err)
+			setHealth("admin", "degraded", err.Error())
+		} else {
+			servers = append(servers, adminSrv)
+			setHealth("admin", "ok", ":"+cfg.Admin.Port)
+		}
+	} else {
+		setHealth("admin", "disabled", "")
+	}
+	if cfg.GRPC.Enabled {
+		if grpcSrv, err = startGRPCServer(cfg); err != nil {
+			log.Printf("[ERROR] gRPC API disabled: %v", err)
+			setHealth("grpc", "degraded", err.Error())
+		} else {
+			setHealth("grpc", "ok", ":"+cfg.GRPC.Port)
+		}
+	} else {
+		setHealth("grpc", "disabled", "")
+	}
+	var sim *simulator
+	if cfg.Topology.File != "" {
+		if sim, err = startTopology(ctx, cfg); err != nil {
+			log.Printf("[ERROR] Topology simulator disabled: %v", err)
+			setHealth("topology", "degraded", err.Error())
+		} else {
+			setHealth("topology", "ok", fmt.Sprintf("%d virtual services", len(sim.services)))
+		}
 	}
 
-	// Create mux with instrumented handlers
-	mux := http.NewServeMux()
-	mux.HandleFunc("/health", healthHandler)
-	mux.HandleFunc("/version", versionHandler)
-	mux.HandleFunc("/api/hello", helloHandler)
-	mux.HandleFunc("/api/status", statusHandler)
-	mux.HandleFunc("/api/feature", featureHandler)
-	mux.HandleFunc("/api/metrics", metricsHandler)
-	mux.HandleFunc("/api/echo", echoHandler)
-	mux.HandleFunc("/api/info", infoHandler)
-	mux.HandleFunc("/api/time", timeHandler)
-	mux.HandleFunc("/api/random", randomHandler)
-	mux.HandleFunc("/", rootHandler)
+	mux := newAppMux()
+
+	// Middleware, applied innermost first: injected faults stand in for the handlers,
+	// validators are computed on the identity body,
+	// authorization runs after authentication,
+	// CORS preflights are answered before load shedding and rate limiting,
+	// and compression wraps everything so problem responses are encoded too
+	var app http.Handler = mux
+	app = injectFaults(app)
+	app = handleConditional(app)
+	app = authorize("/api/", app)
+	app = authenticateAPI(app)
+	app = limitRate(rateLimits, app)
+	app = limitConcurrency(limiter, app)
+	app = handleCORS(app)
+	app = secureHeaders(app)
+	app = compressResponses(app)
+	app = annotateTLS(app)
+	app = recordProtocol(app)
+	app = localize(app)
+	app = publishRequests(app)
 
 	// Wrap with OpenTelemetry HTTP instrumentation
-	handler := otelhttp.NewHandler(mux, "demo-app",
+	handler := otelhttp.NewHandler(app, "demo-app",
 		otelhttp.WithMessageEvents(otelhttp.ReadEvents, otelhttp.WriteEvents),
 	)
+	handler = withSamplingRoute(handler)
+
+	go events.publishMetrics()
+	publishLifecycle("started", "v"+Version)
+	setHealth("http", "ok", ":"+cfg.Port)
+	log.Printf("Demo App v%s starting on port %s", Version, cfg.Port)
+	log.Printf("OpenTelemetry endpoint: %s", cfg.OTLPEndpoint)
+
+	if cfg.Socket != "" {
+		if socketSrv, err := serveUnixSocket(cfg.Socket, handler, cfg.H2C); err != nil {
+			log.Printf("[ERROR] Unix socket disabled: %v", err)
+			setHealth("socket", "degraded", err.Error())
+		} else {
+			servers = append(servers, socketSrv)
+			setHealth("socket", "ok", cfg.Socket)
+		}
+	}
+
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	servers = append(servers, srv)
+	serve := srv.ListenAndServe
+	if cfg.TLS.Enabled {
+		certs, err := newCertReloader(cfg.TLS)
+		if err == nil {
+			srv.TLSConfig, err = certs.tlsConfig()
+		}
+		if err != nil {
+			// Stop through the shutdown path below so the other listeners and telemetry are closed
+			serve = func() error { return fmt.Errorf("TLS setup failed: %w", err) }
+		} else {
+			setHealth("tls", "ok", certs.expiry())
+			go certs.watch(cfg.TLS.ReloadInterval)
+			log.Printf("Serving HTTPS (self-signed: %t, client auth: %s)", cfg.TLS.SelfSigned, cfg.TLS.ClientAuth)
+			serve = func() error { return srv.ListenAndServeTLS("", "") }
+		}
+	} else if cfg.H2C {
+		srv.Handler = withH2C(handler)
+	}
 
-	log.Printf("Demo App v%s starting on port %s", Version, port)
-	log.Printf("OpenTelemetry endpoint: %s", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	served := make(chan error, 1)
+	go func() { served <- serve() }()
+
+	var reason string
+	var serveErr error
+	select {
+	case sig := <-stop:
+		reason = sig.String()
+	case serveErr = <-served:
+		log.Printf("[ERROR] Server stopped: %v", serveErr)
+		reason = serveErr.Error()
+	}
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown)
+	defer cancel()
+	drainServers(drainCtx, reason, servers, grpcSrv)
+	// os.Exit skips deferred calls, so the simulator and tracer are closed explicitly
+	flushTelemetry(drainCtx, sim, tp)
+	log.Printf("Demo App v%s stopped", Version)
+	if serveErr != nil {
+		os.Exit(1)
+	}
 }
 
 
@@ -226,56 +412,6 @@ func getTraceID(ctx context.Context) string {
 	return ""
 }
 
-func rootHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	if tracer != nil {
-		var span trace.Span
-		ctx, span = tracer.Start(ctx, "rootHandler")
-		defer span.End()
-		span.SetAttributes(attribute.String("handler", "root"))
-	}
-
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
-	}
-	atomic.AddInt64(&requestCount, 1)
-	log.Printf("[INFO] Root page accessed, request count: %d, traceId: %s", requestCount, getTraceID(ctx))
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprintf(w, `<!DOCTYPE html>
-<html>
-<head>
-    <title>Demo App v2.5</title>
-    <style>
-        body { font-family: Arial, sans-serif; max-width: 800px; margin: 50px auto; padding: 20px; background: #f0f8ff; }
-        h1 { color: #2e8b57; }
-        .version-badge { background: #2e8b57; color: white; padding: 5px 10px; border-radius: 15px; font-size: 14px; }
-        .endpoint { background: #fff; padding: 10px; margin: 10px 0; border-radius: 5px; border-left: 4px solid #2e8b57; }
-        code { background: #e0e0e0; padding: 2px 6px; border-radius: 3px; }
-        .new-feature { background: #fffacd; border-left-color: #ffa500; }
-        .otel-badge { background: #7B68EE; color: white; padding: 2px 8px; border-radius: 10px; font-size: 12px; margin-left: 10px; }
-    </style>
-</head>
-<body>
-    <h1>🚀 Demo App <span class="version-badge">v2.5 开发版</span> <span class="otel-badge">OpenTelemetry</span></h1>
-    <p>Version: %s</p>
-    <p><strong>🆕 v2.5 新功能：</strong> 集成 OpenTelemetry 分布式追踪和结构化日志！</p>
-    <h2>Available Endpoints:</h2>
-    <div class="endpoint"><strong>GET</strong> <code>/health</code> - Health check</div>
-    <div class="endpoint"><strong>GET</strong> <code>/version</code> - Version info</div>
-    <div class="endpoint"><strong>GET</strong> <code>/api/hello</code> - Hello World (with tracing)</div>
-    <div class="endpoint"><strong>GET</strong> <code>/api/status</code> - Application status</div>
-    <div class="endpoint"><strong>GET</strong> <code>/api/feature</code> - 功能展示</div>
-    <div class="endpoint"><strong>GET</strong> <code>/api/metrics</code> - 应用指标</div>
-    <div class="endpoint"><strong>GET/POST</strong> <code>/api/echo</code> - 请求回显 (with tracing)</div>
-    <div class="endpoint"><strong>GET</strong> <code>/api/info</code> - 应用详细信息</div>
-    <div class="endpoint"><strong>GET</strong> <code>/api/time</code> - 服务器时间信息</div>
-    <div class="endpoint"><strong>GET</strong> <code>/api/random</code> - 随机数据生成 (with tracing)</div>
-</body>
-</html>`, Version)
-}
-
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log.Printf("[INFO] Health check, traceId: %s", getTraceID(ctx))
@@ -287,6 +423,22 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, response)
 }
 
+// readyHandler answers 503 while any dependency check fails, so probes and
+// load balancers stop sending traffic that cannot be served
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	response := ReadinessResponse{
+		Status:    "ready",
+		Checks:    healthChecks(),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	status := http.StatusOK
+	if !healthy() {
+		response.Status = "unready"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, response)
+}
+
 func versionHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log.Printf("[INFO] Version info requested, traceId: %s", getTraceID(ctx))
@@ -316,9 +468,10 @@ func helloHandler(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 
+	p := printerFromContext(ctx)
 	name := r.URL.Query().Get("name")
 	if name == "" {
-		name = "World"
+		name = p.Sprintf("greeting.default_name")
 	}
 
 	// Simulate some processing time
@@ -327,13 +480,23 @@ func helloHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[INFO] Hello endpoint called, name: %s, traceId: %s", name, getTraceID(ctx))
 
 	response := HelloResponse{
-		Message:   fmt.Sprintf("Hello, %s! 👋 (v2.5 with OpenTelemetry)", name),
+		Message:   greeting(ctx, name),
+		Locale:    p.Locale,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		TraceID:   getTraceID(ctx),
 	}
 	writeJSON(w, http.StatusOK, response)
 }
 
+// greeting is shared by the HTTP and gRPC Hello endpoints
+func greeting(ctx context.Context, name string) string {
+	p := printerFromContext(ctx)
+	if name == "" {
+		name = p.Sprintf("greeting.default_name")
+	}
+	return p.Sprintf("greeting", name)
+}
+
 func statusHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	
@@ -343,22 +506,24 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 		defer span.End()
 	}
 
+	response := newStatusResponse(ctx)
+	log.Printf("[INFO] Status check, env: %s, uptime: %s, traceId: %s", response.Environment, response.Uptime, getTraceID(ctx))
+	writeJSON(w, http.StatusOK, response)
+}
+
+func newStatusResponse(ctx context.Context) StatusResponse {
 	env := os.Getenv("APP_ENV")
 	if env == "" {
 		env = "development"
 	}
 
-	uptime := time.Since(startTime)
-	log.Printf("[INFO] Status check, env: %s, uptime: %s, traceId: %s", env, uptime.Round(time.Second), getTraceID(ctx))
-
-	response := StatusResponse{
+	return StatusResponse{
 		Status:      "running",
 		Environment: env,
-		Uptime:      uptime.Round(time.Second).String(),
+		Uptime:      time.Since(startTime).Round(time.Second).String(),
 		Timestamp:   time.Now().UTC().Format(time.RFC3339),
 		TraceID:     getTraceID(ctx),
 	}
-	writeJSON(w, http.StatusOK, response)
 }
 
 func featureHandler(w http.ResponseWriter, r *http.Request) {
@@ -366,10 +531,12 @@ func featureHandler(w http.ResponseWriter, r *http.Request) {
 	atomic.AddInt64(&requestCount, 1)
 	log.Printf("[INFO] Feature endpoint called, traceId: %s", getTraceID(ctx))
 	
+	p := printerFromContext(ctx)
 	response := FeatureResponse{
-		Feature:     "OpenTelemetry 集成",
-		Description: "这是 v2.5 开发版，支持分布式追踪和结构化日志",
+		Feature:     p.Sprintf("feature.name"),
+		Description: p.Sprintf("feature.description"),
 		Version:     Version,
+		Locale:      p.Locale,
 		Timestamp:   time.Now().UTC().Format(time.RFC3339),
 	}
 	writeJSON(w, http.StatusOK, response)
@@ -379,20 +546,35 @@ func metricsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	atomic.AddInt64(&requestCount, 1)
 
+	response := collectMetrics()
+	log.Printf("[INFO] Metrics requested, requestCount: %d, memory: %s, traceId: %s",
+		response.RequestCount, response.MemoryUsage, getTraceID(ctx))
+	writeJSON(w, http.StatusOK, response)
+}
+
+// collectMetrics takes a snapshot of the application metrics
+func collectMetrics() MetricsResponse {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	log.Printf("[INFO] Metrics requested, requestCount: %d, memory: %.2f MB, traceId: %s",
-		requestCount, float64(m.Alloc)/1024/1024, getTraceID(ctx))
-
-	response := MetricsResponse{
+	return MetricsResponse{
 		RequestCount: atomic.LoadInt64(&requestCount),
 		MemoryUsage:  fmt.Sprintf("%.2f MB", float64(m.Alloc)/1024/1024),
 		GoRoutines:   runtime.NumGoroutine(),
 		Uptime:       time.Since(startTime).Round(time.Second).String(),
+		Process:      readProcessMetrics(),
+		GC:           readGCMetrics(&m),
+		Container:    readContainerMetrics(),
+		Runtime:      readRuntimeMetrics(),
+		Stress:       stress.Active(),
+		Concurrency:  limiter.metrics(),
+		RateLimit:    rateLimits.metrics(),
+		Authz:        authorizationMetrics(),
+		Compression:  compressionMetrics(),
+		Protocols:    protocolMetrics(),
+		WebSockets:   webSocketMetrics(),
 		Timestamp:    time.Now().UTC().Format(time.RFC3339),
 	}
-	writeJSON(w, http.StatusOK, response)
 }
 
 
@@ -416,10 +598,16 @@ func echoHandler(w http.ResponseWriter, r *http.Request) {
 			headers[key] = values[0]
 		}
 	}
+	// Never echo credentials back
+	for _, key := range []string{"Authorization", "X-Api-Key", "Cookie"} {
+		if _, ok := headers[key]; ok {
+			headers[key] = "[redacted]"
+		}
+	}
 
 	echo := r.URL.Query().Get("message")
 	if echo == "" {
-		echo = "Hello from Echo API with OpenTelemetry!"
+		echo = printerFromContext(ctx).Sprintf("echo.default")
 	}
 
 	// Simulate database call
@@ -437,9 +625,14 @@ func echoHandler(w http.ResponseWriter, r *http.Request) {
 		Headers:   headers,
 		Method:    r.Method,
 		Path:      r.URL.Path,
+		Protocol:  protocolName(r),
+		TLS:       tlsInfo(r.TLS),
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		TraceID:   getTraceID(ctx),
 	}
+	if p := principalFromContext(ctx); p != nil {
+		response.Subject = p.Subject
+	}
 	writeJSON(w, http.StatusOK, response)
 }
 
@@ -447,17 +640,21 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	atomic.AddInt64(&requestCount, 1)
 	log.Printf("[INFO] Info endpoint called, traceId: %s", getTraceID(ctx))
+	p := printerFromContext(ctx)
 
 	response := InfoResponse{
 		AppName:     "Demo App",
 		Version:     Version,
-		Description: "A demo application with OpenTelemetry for CI/CD pipeline testing",
+		Description: p.Sprintf("info.description"),
+		Locale:      p.Locale,
 		Author:      "CI/CD Platform Team",
 		GoVersion:   runtime.Version(),
 		OS:          runtime.GOOS,
 		Arch:        runtime.GOARCH,
 		Timestamp:   time.Now().UTC().Format(time.RFC3339),
 	}
+	// The timestamp changes on every call, so the validator covers only the stable fields
+	setETagSource(ctx, []byte(response.Version), []byte(response.GoVersion), []byte(response.OS+"/"+response.Arch), []byte(response.Locale))
 	writeJSON(w, http.StatusOK, response)
 }
 
@@ -466,13 +663,16 @@ func timeHandler(w http.ResponseWriter, r *http.Request) {
 	atomic.AddInt64(&requestCount, 1)
 
 	now := time.Now()
+	log.Printf("[INFO] Time endpoint called, serverTime: %s, traceId: %s", now.Format(time.RFC3339), getTraceID(ctx))
+	writeJSON(w, http.StatusOK, newTimeResponse(now))
+}
+
+func newTimeResponse(now time.Time) TimeResponse {
 	_, week := now.ISOWeek()
 	dayOfWeek := now.Weekday()
 	isWeekend := dayOfWeek == time.Saturday || dayOfWeek == time.Sunday
 
-	log.Printf("[INFO] Time endpoint called, serverTime: %s, traceId: %s", now.Format(time.RFC3339), getTraceID(ctx))
-
-	response := TimeResponse{
+	return TimeResponse{
 		ServerTime: now.Format("2006-01-02 15:04:05"),
 		Timezone:   now.Location().String(),
 		UnixTime:   now.Unix(),
@@ -481,14 +681,16 @@ func timeHandler(w http.ResponseWriter, r *http.Request) {
 		IsWeekend:  isWeekend,
 		Timestamp:  now.UTC().Format(time.RFC3339),
 	}
-	writeJSON(w, http.StatusOK, response)
 }
 
 
 func randomHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
 	atomic.AddInt64(&requestCount, 1)
+	writeJSON(w, http.StatusOK, newRandomResponse(r.Context()))
+}
 
+// newRandomResponse generates the random payload shared by the HTTP and gRPC endpoints
+func newRandomResponse(ctx context.Context) RandomResponse {
 	if tracer != nil {
 		var span trace.Span
 		ctx, span = tracer.Start(ctx, "randomHandler.generateData")
@@ -511,13 +713,8 @@ func randomHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	colors := []string{"#FF6B6B", "#4ECDC4", "#45B7D1", "#96CEB4", "#FFEAA7", "#DDA0DD", "#98D8C8", "#F7DC6F"}
-	quotes := []string{
-		"代码是写给人看的，顺便能在机器上运行。",
-		"先让它工作，再让它正确，最后让它快。",
-		"简单是可靠的先决条件。",
-		"过早优化是万恶之源。",
-		"好的代码是它自己最好的文档。",
-	}
+	p := printerFromContext(ctx)
+	const quotes = 5 // random.quote.1 to random.quote.5 in the catalogs
 
 	dice := make([]int, 3)
 	for i := range dice {
@@ -529,25 +726,27 @@ func randomHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Simulate occasional errors for testing
 	if randomNum > 950 {
+		err := fmt.Errorf("simulated error: random number too high")
 		if tracer != nil {
 			span := trace.SpanFromContext(ctx)
 			span.SetStatus(codes.Error, "Random error for testing")
-			span.RecordError(fmt.Errorf("simulated error: random number too high"))
+			span.RecordError(err)
 		}
+		markRequestError(ctx, err)
 		log.Printf("[ERROR] Simulated error occurred, number: %d, traceId: %s", randomNum, getTraceID(ctx))
 	}
 
-	response := RandomResponse{
+	return RandomResponse{
 		Number:      randomNum,
 		UUID:        fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", rand.Int63(), rand.Int31()&0xffff, rand.Int31()&0xffff, rand.Int31()&0xffff, rand.Int63()),
 		Color:       colors[rand.Intn(len(colors))],
-		Quote:       quotes[rand.Intn(len(quotes))],
+		Quote:       p.Sprintf(fmt.Sprintf("random.quote.%d", rand.Intn(quotes)+1)),
+		Locale:      p.Locale,
 		LuckyNumber: rand.Intn(100) + 1,
 		Dice:        dice,
 		Timestamp:   time.Now().UTC().Format(time.RFC3339),
 		TraceID:     getTraceID(ctx),
 	}
-	writeJSON(w, http.StatusOK, response)
 }
 
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -32,6 +32,42 @@ func TestHealthHandler(t *testing.T) {
 	}
 }
 
+func TestReadyHandler(t *testing.T) {
+	defer healthState.Delete("zz-test")
+
+	tests := []struct {
+		name     string
+		check    string
+		code     int
+		expected string
+	}{
+		{"passing", "ok", http.StatusOK, "ready"},
+		{"failing", "fail", http.StatusServiceUnavailable, "unready"},
+		{"optional listener down", "degraded", http.StatusOK, "ready"},
+		{"recovered", "ok", http.StatusOK, "ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setHealth("zz-test", tt.check, "")
+			rr := httptest.NewRecorder()
+			readyHandler(rr, httptest.NewRequest("GET", "/ready", nil))
+			if rr.Code != tt.code {
+				t.Errorf("got status %d want %d", rr.Code, tt.code)
+			}
+			var response ReadinessResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if response.Status != tt.expected {
+				t.Errorf("got %q want %q", response.Status, tt.expected)
+			}
+			if len(response.Checks) == 0 {
+				t.Error("expected the health checks in the response")
+			}
+		})
+	}
+}
+
 func TestVersionHandler(t *testing.T) {
 	req, err := http.NewRequest("GET", "/version", nil)
 	if err != nil {
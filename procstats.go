@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locations of the proc and cgroup v2 filesystems, overridable in tests
+var (
+	procRoot   = "/proc"
+	cgroupRoot = "/sys/fs/cgroup"
+)
+
+// clockTicks is USER_HZ, which is 100 on every Linux platform Go supports
+const clockTicks = 100
+
+type ProcessMetrics struct {
+	RSSBytes      int64   `json:"rssBytes"`
+	OpenFDs       int     `json:"openFds"`
+	MaxFDs        int64   `json:"maxFds"`
+	Threads       int     `json:"threads"`
+	CPUUserSecs   float64 `json:"cpuUserSeconds"`
+	CPUSystemSecs float64 `json:"cpuSystemSeconds"`
+}
+
+type GCMetrics struct {
+	NumGC          int64    `json:"numGC"`
+	PauseTotal     string   `json:"pauseTotal"`
+	LastPause      string   `json:"lastPause"`
+	PauseQuantiles []string `json:"pauseQuantiles"` // min, p25, p50, p75, max
+	LastGC         string   `json:"lastGC,omitempty"`
+	HeapAllocBytes uint64   `json:"heapAllocBytes"`
+	HeapSysBytes   uint64   `json:"heapSysBytes"`
+	NextGCBytes    uint64   `json:"nextGCBytes"`
+}
+
+type ContainerMetrics struct {
+	MemoryLimitBytes int64   `json:"memoryLimitBytes"` // -1 when unlimited
+	MemoryUsageBytes int64   `json:"memoryUsageBytes"`
+	CPUQuotaCores    float64 `json:"cpuQuotaCores"` // 0 when unlimited
+	CPUPeriods       int64   `json:"cpuPeriods"`
+	CPUThrottled     int64   `json:"cpuThrottledPeriods"`
+	CPUThrottledTime string  `json:"cpuThrottledTime"`
+}
+
+type RuntimeMetrics struct {
+	GOMAXPROCS    int    `json:"gomaxprocs"`
+	NumCPU        int    `json:"numCPU"`
+	GOMEMLIMIT    int64  `json:"gomemlimit"` // math.MaxInt64 when unset
+	GOGC          string `json:"gogc"`
+	GoVersion     string `json:"goVersion"`
+	CgroupVersion string `json:"cgroupVersion,omitempty"`
+}
+
+// readProcessMetrics reads resource usage of this process from /proc.
+// It returns nil where /proc is not available.
+func readProcessMetrics() *ProcessMetrics {
+	status, err := readKeyValues(filepath.Join(procRoot, "self", "status"), ":")
+	if err != nil {
+		return nil
+	}
+	pm := &ProcessMetrics{MaxFDs: -1}
+	if rss, ok := status["VmRSS"]; ok {
+		pm.RSSBytes = parseKB(rss)
+	}
+	pm.Threads, _ = strconv.Atoi(status["Threads"])
+
+	if entries, err := os.ReadDir(filepath.Join(procRoot, "self", "fd")); err == nil {
+		pm.OpenFDs = len(entries)
+	}
+	pm.MaxFDs = readMaxOpenFiles()
+
+	if stat, err := os.ReadFile(filepath.Join(procRoot, "self", "stat")); err == nil {
+		// Fields after the parenthesised command name; utime and stime are fields 14 and 15
+		s := string(stat)
+		if i := strings.LastIndexByte(s, ')'); i >= 0 {
+			fields := strings.Fields(s[i+1:])
+			if len(fields) > 12 {
+				utime, _ := strconv.ParseFloat(fields[11], 64)
+				stime, _ := strconv.ParseFloat(fields[12], 64)
+				pm.CPUUserSecs = utime / clockTicks
+				pm.CPUSystemSecs = stime / clockTicks
+			}
+		}
+	}
+	return pm
+}
+
+func readMaxOpenFiles() int64 {
+	f, err := os.Open(filepath.Join(procRoot, "self", "limits"))
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 || fields[0] == "unlimited" {
+			return -1
+		}
+		v, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return -1
+		}
+		return v
+	}
+	return -1
+}
+
+// readGCMetrics summarizes garbage collector activity
+func readGCMetrics(m *runtime.MemStats) GCMetrics {
+	stats := debug.GCStats{PauseQuantiles: make([]time.Duration, 5)}
+	debug.ReadGCStats(&stats)
+
+	gm := GCMetrics{
+		NumGC:          stats.NumGC,
+		PauseTotal:     stats.PauseTotal.String(),
+		HeapAllocBytes: m.HeapAlloc,
+		HeapSysBytes:   m.HeapSys,
+		NextGCBytes:    m.NextGC,
+	}
+	if len(stats.Pause) > 0 {
+		gm.LastPause = stats.Pause[0].String()
+	}
+	if !stats.LastGC.IsZero() {
+		gm.LastGC = stats.LastGC.UTC().Format(time.RFC3339)
+	}
+	for _, q := range stats.PauseQuantiles {
+		gm.PauseQuantiles = append(gm.PauseQuantiles, q.String())
+	}
+	return gm
+}
+
+// readContainerMetrics reads memory and CPU accounting from cgroup v2.
+// It returns nil when the process is not in a cgroup v2 hierarchy.
+func readContainerMetrics() *ContainerMetrics {
+	current, err := readInt(filepath.Join(cgroupRoot, "memory.current"))
+	if err != nil {
+		return nil
+	}
+	cm := &ContainerMetrics{MemoryUsageBytes: current, MemoryLimitBytes: -1}
+
+	if max, err := os.ReadFile(filepath.Join(cgroupRoot, "memory.max")); err == nil {
+		if v, err := strconv.ParseInt(strings.TrimSpace(string(max)), 10, 64); err == nil {
+			cm.MemoryLimitBytes = v
+		}
+	}
+
+	if cpuMax, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu.max")); err == nil {
+		fields := strings.Fields(string(cpuMax))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, _ := strconv.ParseFloat(fields[0], 64)
+			period, _ := strconv.ParseFloat(fields[1], 64)
+			if period > 0 {
+				cm.CPUQuotaCores = quota / period
+			}
+		}
+	}
+
+	if stat, err := readKeyValues(filepath.Join(cgroupRoot, "cpu.stat"), " "); err == nil {
+		cm.CPUPeriods, _ = strconv.ParseInt(stat["nr_periods"], 10, 64)
+		cm.CPUThrottled, _ = strconv.ParseInt(stat["nr_throttled"], 10, 64)
+		usec, _ := strconv.ParseInt(stat["throttled_usec"], 10, 64)
+		cm.CPUThrottledTime = (time.Duration(usec) * time.Microsecond).String()
+	}
+	return cm
+}
+
+// readRuntimeMetrics reports the effective Go scheduler and memory settings
+func readRuntimeMetrics() RuntimeMetrics {
+	rm := RuntimeMetrics{
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		GOMEMLIMIT: debug.SetMemoryLimit(-1),
+		GOGC:       getEnv("GOGC", "100"),
+		GoVersion:  runtime.Version(),
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		rm.CgroupVersion = "v2"
+	}
+	return rm
+}
+
+// readKeyValues parses files made of "key<sep>value" lines
+func readKeyValues(path, sep string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), sep)
+		if ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values, scanner.Err()
+}
+
+func readInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// parseKB converts values like "1234 kB" into bytes
+func parseKB(v string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(v, "kB")), 10, 64)
+	return n * 1024
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadProcessMetrics(t *testing.T) {
+	dir := t.TempDir()
+	defer func(old string) { procRoot = old }(procRoot)
+	procRoot = dir
+
+	writeFile(t, filepath.Join(dir, "self", "status"), "Name:\tdemo-app\nVmRSS:\t   2048 kB\nThreads:\t7\n")
+	writeFile(t, filepath.Join(dir, "self", "limits"), "Limit Soft Limit Hard Limit Units\nMax open files            1024                 4096                 files\n")
+	writeFile(t, filepath.Join(dir, "self", "stat"), "1 (demo app) S 0 1 1 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 7 0")
+	writeFile(t, filepath.Join(dir, "self", "fd", "0"), "")
+	writeFile(t, filepath.Join(dir, "self", "fd", "1"), "")
+
+	pm := readProcessMetrics()
+	if pm == nil {
+		t.Fatal("expected process metrics")
+	}
+	if pm.RSSBytes != 2048*1024 {
+		t.Errorf("unexpected rss: got %v want %v", pm.RSSBytes, 2048*1024)
+	}
+	if pm.Threads != 7 {
+		t.Errorf("unexpected threads: got %v want %v", pm.Threads, 7)
+	}
+	if pm.OpenFDs != 2 || pm.MaxFDs != 1024 {
+		t.Errorf("unexpected fds: got %v/%v want 2/1024", pm.OpenFDs, pm.MaxFDs)
+	}
+	if pm.CPUUserSecs != 2.5 || pm.CPUSystemSecs != 0.5 {
+		t.Errorf("unexpected cpu time: got %v/%v want 2.5/0.5", pm.CPUUserSecs, pm.CPUSystemSecs)
+	}
+}
+
+func TestReadContainerMetrics(t *testing.T) {
+	dir := t.TempDir()
+	defer func(old string) { cgroupRoot = old }(cgroupRoot)
+	cgroupRoot = dir
+
+	if cm := readContainerMetrics(); cm != nil {
+		t.Errorf("expected nil outside of a cgroup v2 hierarchy, got %+v", cm)
+	}
+
+	writeFile(t, filepath.Join(dir, "memory.current"), "1048576\n")
+	writeFile(t, filepath.Join(dir, "memory.max"), "max\n")
+	writeFile(t, filepath.Join(dir, "cpu.max"), "50000 100000\n")
+	writeFile(t, filepath.Join(dir, "cpu.stat"), "usage_usec 100\nnr_periods 40\nnr_throttled 3\nthrottled_usec 1500000\n")
+
+	cm := readContainerMetrics()
+	if cm == nil {
+		t.Fatal("expected container metrics")
+	}
+	if cm.MemoryUsageBytes != 1048576 || cm.MemoryLimitBytes != -1 {
+		t.Errorf("unexpected memory: got %v/%v want 1048576/-1", cm.MemoryUsageBytes, cm.MemoryLimitBytes)
+	}
+	if cm.CPUQuotaCores != 0.5 {
+		t.Errorf("unexpected cpu quota: got %v want %v", cm.CPUQuotaCores, 0.5)
+	}
+	if cm.CPUPeriods != 40 || cm.CPUThrottled != 3 || cm.CPUThrottledTime != "1.5s" {
+		t.Errorf("unexpected throttling: %+v", cm)
+	}
+}
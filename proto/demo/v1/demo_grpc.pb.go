@@ -0,0 +1,267 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: demo/v1/demo.proto
+
+package demov1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	DemoService_Hello_FullMethodName  = "/demo.v1.DemoService/Hello"
+	DemoService_Echo_FullMethodName   = "/demo.v1.DemoService/Echo"
+	DemoService_Status_FullMethodName = "/demo.v1.DemoService/Status"
+	DemoService_Time_FullMethodName   = "/demo.v1.DemoService/Time"
+	DemoService_Random_FullMethodName = "/demo.v1.DemoService/Random"
+)
+
+// DemoServiceClient is the client API for DemoService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DemoServiceClient interface {
+	// Hello greets the caller, like GET /api/hello.
+	Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error)
+	// Echo returns the message and request metadata, like /api/echo.
+	Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error)
+	// Status reports the environment and uptime, like GET /api/status.
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// Time describes the server clock, like GET /api/time.
+	Time(ctx context.Context, in *TimeRequest, opts ...grpc.CallOption) (*TimeResponse, error)
+	// Random generates random data and occasionally fails, like GET /api/random.
+	Random(ctx context.Context, in *RandomRequest, opts ...grpc.CallOption) (*RandomResponse, error)
+}
+
+type demoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDemoServiceClient(cc grpc.ClientConnInterface) DemoServiceClient {
+	return &demoServiceClient{cc}
+}
+
+func (c *demoServiceClient) Hello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloResponse, error) {
+	out := new(HelloResponse)
+	err := c.cc.Invoke(ctx, DemoService_Hello_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *demoServiceClient) Echo(ctx context.Context, in *EchoRequest, opts ...grpc.CallOption) (*EchoResponse, error) {
+	out := new(EchoResponse)
+	err := c.cc.Invoke(ctx, DemoService_Echo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *demoServiceClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, DemoService_Status_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *demoServiceClient) Time(ctx context.Context, in *TimeRequest, opts ...grpc.CallOption) (*TimeResponse, error) {
+	out := new(TimeResponse)
+	err := c.cc.Invoke(ctx, DemoService_Time_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *demoServiceClient) Random(ctx context.Context, in *RandomRequest, opts ...grpc.CallOption) (*RandomResponse, error) {
+	out := new(RandomResponse)
+	err := c.cc.Invoke(ctx, DemoService_Random_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DemoServiceServer is the server API for DemoService service.
+// All implementations must embed UnimplementedDemoServiceServer
+// for forward compatibility
+type DemoServiceServer interface {
+	// Hello greets the caller, like GET /api/hello.
+	Hello(context.Context, *HelloRequest) (*HelloResponse, error)
+	// Echo returns the message and request metadata, like /api/echo.
+	Echo(context.Context, *EchoRequest) (*EchoResponse, error)
+	// Status reports the environment and uptime, like GET /api/status.
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	// Time describes the server clock, like GET /api/time.
+	Time(context.Context, *TimeRequest) (*TimeResponse, error)
+	// Random generates random data and occasionally fails, like GET /api/random.
+	Random(context.Context, *RandomRequest) (*RandomResponse, error)
+	mustEmbedUnimplementedDemoServiceServer()
+}
+
+// UnimplementedDemoServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedDemoServiceServer struct {
+}
+
+func (UnimplementedDemoServiceServer) Hello(context.Context, *HelloRequest) (*HelloResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Hello not implemented")
+}
+func (UnimplementedDemoServiceServer) Echo(context.Context, *EchoRequest) (*EchoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Echo not implemented")
+}
+func (UnimplementedDemoServiceServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedDemoServiceServer) Time(context.Context, *TimeRequest) (*TimeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Time not implemented")
+}
+func (UnimplementedDemoServiceServer) Random(context.Context, *RandomRequest) (*RandomResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Random not implemented")
+}
+func (UnimplementedDemoServiceServer) mustEmbedUnimplementedDemoServiceServer() {}
+
+// UnsafeDemoServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DemoServiceServer will
+// result in compilation errors.
+type UnsafeDemoServiceServer interface {
+	mustEmbedUnimplementedDemoServiceServer()
+}
+
+func RegisterDemoServiceServer(s grpc.ServiceRegistrar, srv DemoServiceServer) {
+	s.RegisterService(&DemoService_ServiceDesc, srv)
+}
+
+func _DemoService_Hello_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HelloRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DemoServiceServer).Hello(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DemoService_Hello_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DemoServiceServer).Hello(ctx, req.(*HelloRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DemoService_Echo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EchoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DemoServiceServer).Echo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DemoService_Echo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DemoServiceServer).Echo(ctx, req.(*EchoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DemoService_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DemoServiceServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DemoService_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DemoServiceServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DemoService_Time_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TimeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DemoServiceServer).Time(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DemoService_Time_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DemoServiceServer).Time(ctx, req.(*TimeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DemoService_Random_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RandomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DemoServiceServer).Random(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DemoService_Random_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DemoServiceServer).Random(ctx, req.(*RandomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DemoService_ServiceDesc is the grpc.ServiceDesc for DemoService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DemoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "demo.v1.DemoService",
+	HandlerType: (*DemoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Hello",
+			Handler:    _DemoService_Hello_Handler,
+		},
+		{
+			MethodName: "Echo",
+			Handler:    _DemoService_Echo_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _DemoService_Status_Handler,
+		},
+		{
+			MethodName: "Time",
+			Handler:    _DemoService_Time_Handler,
+		},
+		{
+			MethodName: "Random",
+			Handler:    _DemoService_Random_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "demo/v1/demo.proto",
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var protocolCounts = struct {
+	sync.Mutex
+	byProtocol map[string]int64
+}{byProtocol: make(map[string]int64)}
+
+// ProtocolMetrics counts requests by negotiated protocol: http/1.0, http/1.1, h2 (TLS) or h2c
+type ProtocolMetrics struct {
+	Requests map[string]int64 `json:"requests"`
+}
+
+// protocolName identifies the wire protocol of a request
+func protocolName(r *http.Request) string {
+	switch {
+	case r.ProtoMajor == 2 && r.TLS != nil:
+		return "h2"
+	case r.ProtoMajor == 2:
+		return "h2c"
+	case r.ProtoMajor == 1 && r.ProtoMinor == 0:
+		return "http/1.0"
+	case r.ProtoMajor == 1:
+		return "http/1.1"
+	}
+	return r.Proto
+}
+
+// withH2C serves HTTP/2 over cleartext connections, both with prior knowledge
+// and via "Upgrade: h2c", alongside HTTP/1.1
+func withH2C(handler http.Handler) http.Handler {
+	return h2c.NewHandler(handler, &http2.Server{})
+}
+
+// serveUnixSocket serves handler over plain HTTP on path, replacing a socket left
+// behind by a previous run. Local probes and sidecars use it to skip TLS and the network.
+func serveUnixSocket(path string, handler http.Handler, h2c bool) (*http.Server, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o660); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if h2c {
+		handler = withH2C(handler)
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[ERROR] Unix socket server stopped: %v", err)
+		}
+	}()
+	log.Printf("Serving HTTP on unix socket %s", path)
+	return srv, nil
+}
+
+// recordProtocol counts requests per protocol and records it on the server span
+func recordProtocol(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto := protocolName(r)
+		protocolCounts.Lock()
+		protocolCounts.byProtocol[proto]++
+		protocolCounts.Unlock()
+
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attribute.String("network.protocol.name", "http"),
+			attribute.String("network.protocol.version", strings.TrimPrefix(r.Proto, "HTTP/")),
+			attribute.String("http.protocol", proto),
+		)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func protocolMetrics() ProtocolMetrics {
+	protocolCounts.Lock()
+	defer protocolCounts.Unlock()
+	m := ProtocolMetrics{Requests: make(map[string]int64, len(protocolCounts.byProtocol))}
+	for proto, n := range protocolCounts.byProtocol {
+		m.Requests[proto] = n
+	}
+	return m
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func TestH2C(t *testing.T) {
+	srv := httptest.NewServer(withH2C(recordProtocol(http.HandlerFunc(echoHandler))))
+	defer srv.Close()
+	before := protocolMetrics().Requests
+
+	priorKnowledge := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+
+	tests := []struct {
+		name     string
+		client   *http.Client
+		expected string
+	}{
+		{"http/1.1", srv.Client(), "http/1.1"},
+		{"prior knowledge", priorKnowledge, "h2c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get(srv.URL + "/api/echo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var echo EchoResponse
+			if err := json.NewDecoder(resp.Body).Decode(&echo); err != nil {
+				t.Fatal(err)
+			}
+			if echo.Protocol != tt.expected {
+				t.Errorf("unexpected protocol: got %q want %q", echo.Protocol, tt.expected)
+			}
+		})
+	}
+
+	// Upgrade from HTTP/1.1; the server answers the upgraded request over HTTP/2
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /api/echo HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade, HTTP2-Settings\r\n" +
+		"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n"))
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(status, "101") {
+		t.Errorf("expected 101 Switching Protocols, got %q", status)
+	}
+
+	after := protocolMetrics().Requests
+	if after["h2c"] <= before["h2c"] || after["http/1.1"] <= before["http/1.1"] {
+		t.Errorf("protocol counts not updated: before %v, after %v", before, after)
+	}
+}
+
+func TestProtocolName(t *testing.T) {
+	tests := []struct {
+		major, minor int
+		tls          bool
+		expected     string
+	}{
+		{1, 0, false, "http/1.0"},
+		{1, 1, false, "http/1.1"},
+		{2, 0, false, "h2c"},
+		{2, 0, true, "h2"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.ProtoMajor, r.ProtoMinor = tt.major, tt.minor
+		if tt.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if got := protocolName(r); got != tt.expected {
+			t.Errorf("protocolName(%d.%d, tls %v): got %q want %q", tt.major, tt.minor, tt.tls, got, tt.expected)
+		}
+	}
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "app.sock")
+	if _, err := serveUnixSocket(socket, http.HandlerFunc(healthHandler), true); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://localhost/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d over the socket", resp.StatusCode)
+	}
+
+	// Restarting over a stale socket works, but a regular file is never removed
+	if _, err := serveUnixSocket(socket, http.HandlerFunc(healthHandler), false); err != nil {
+		t.Errorf("stale socket was not replaced: %v", err)
+	}
+	file := filepath.Join(dir, "data.txt")
+	os.WriteFile(file, []byte("keep"), 0o600)
+	if _, err := serveUnixSocket(file, http.HandlerFunc(healthHandler), false); err == nil {
+		t.Error("a regular file must not be replaced")
+	}
+	if data, _ := os.ReadFile(file); string(data) != "keep" {
+		t.Error("the regular file was modified")
+	}
+}
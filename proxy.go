@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hopBaggageKey counts the proxy hops a request has taken, so chains cannot loop forever
+const hopBaggageKey = "demo.hop"
+
+var proxyConfig atomic.Pointer[ProxyConfig]
+
+func init() {
+	proxyConfig.Store(&ProxyConfig{})
+}
+
+func (cfg *ProxyConfig) validate() error {
+	if cfg.Timeout <= 0 || cfg.MaxBodyBytes <= 0 {
+		return fmt.Errorf("proxy: timeout (%s) and max body bytes (%d) must be positive", cfg.Timeout, cfg.MaxBodyBytes)
+	}
+	return nil
+}
+
+// proxyClient injects traceparent and baggage into every outbound request
+var proxyClient = &http.Client{
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("stopped after 5 redirects")
+		}
+		if !proxyConfig.Load().allowed(req.URL) {
+			return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+		}
+		return nil
+	},
+}
+
+// ProxyTiming breaks the outbound call down by phase, in milliseconds.
+// DNS, connect and TLS are zero when a pooled connection was reused.
+type ProxyTiming struct {
+	DNSMs       float64 `json:"dnsMs"`
+	ConnectMs   float64 `json:"connectMs"`
+	TLSMs       float64 `json:"tlsMs"`
+	FirstByteMs float64 `json:"firstByteMs"` // from sending the request to the first response byte
+	TotalMs     float64 `json:"totalMs"`
+	ReusedConn  bool    `json:"reusedConn"`
+}
+
+type ProxyResponse struct {
+	Target      string          `json:"target"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"contentType,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"` // JSON is embedded as-is, anything else as a string
+	Truncated   bool            `json:"truncated,omitempty"`
+	Timing      ProxyTiming     `json:"timing"`
+	Hop         int             `json:"hop"`
+	TraceID     string          `json:"traceId,omitempty"`
+	Timestamp   string          `json:"timestamp"`
+}
+
+// ChainResponse is returned by every hop of /api/chain, nesting the next hop's response
+type ChainResponse struct {
+	Service    string         `json:"service"`
+	Hop        int            `json:"hop"`
+	Message    string         `json:"message"`
+	Downstream *ProxyResponse `json:"downstream,omitempty"`
+	TraceID    string         `json:"traceId,omitempty"`
+	Timestamp  string         `json:"timestamp"`
+}
+
+// allowed reports whether u may be called. Entries match a host name on any port,
+// an exact host:port, or with a leading "*." any subdomain.
+func (cfg *ProxyConfig) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, entry := range cfg.AllowedHosts {
+		entry = strings.ToLower(entry)
+		switch {
+		case entry == host, entry == strings.ToLower(u.Host):
+			return true
+		case strings.HasPrefix(entry, "*.") && strings.HasSuffix(host, entry[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// currentHop returns the hop count carried in the incoming baggage
+func currentHop(ctx context.Context) int {
+	hop, _ := strconv.Atoi(baggage.FromContext(ctx).Member(hopBaggageKey).Value())
+	return hop
+}
+
+// withNextHop copies the incoming baggage with the hop count incremented
+func withNextHop(ctx context.Context, hop int) context.Context {
+	member, err := baggage.NewMember(hopBaggageKey, strconv.Itoa(hop+1))
+	if err != nil {
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// timingTrace records when each phase of the connection happened
+type timingTrace struct {
+	mu                                           sync.Mutex
+	dnsStart, dnsDone, connectStart, connectDone time.Time
+	tlsStart, tlsDone, wroteRequest, firstByte   time.Time
+	reused                                       bool
+}
+
+func (t *timingTrace) mark(at *time.Time) func() {
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if at.IsZero() {
+			*at = time.Now()
+		}
+	}
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart)() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone)() },
+		ConnectStart:         func(string, string) { t.mark(&t.connectStart)() },
+		ConnectDone:          func(string, string, error) { t.mark(&t.connectDone)() },
+		TLSHandshakeStart:    t.mark(&t.tlsStart),
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.mark(&t.tlsDone)() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest)() },
+		GotFirstResponseByte: t.mark(&t.firstByte),
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+	}
+}
+
+func (t *timingTrace) timing(start time.Time) ProxyTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ms := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return 0
+		}
+		return float64(to.Sub(from).Microseconds()) / 1000
+	}
+	return ProxyTiming{
+		DNSMs:       ms(t.dnsStart, t.dnsDone),
+		ConnectMs:   ms(t.connectStart, t.connectDone),
+		TLSMs:       ms(t.tlsStart, t.tlsDone),
+		FirstByteMs: ms(t.wroteRequest, t.firstByte),
+		TotalMs:     ms(start, time.Now()),
+		ReusedConn:  t.reused,
+	}
+}
+
+// callDownstream performs the instrumented GET and writes a problem response on failure
+func callDownstream(w http.ResponseWriter, r *http.Request, target string) (*ProxyResponse, bool) {
+	ctx := r.Context()
+	cfg := proxyConfig.Load()
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		writeProblem(w, r, http.StatusBadRequest, "target must be an absolute http or https URL")
+		return nil, false
+	}
+	if !cfg.allowed(u) {
+		log.Printf("[WARN] Proxy target rejected, host: %s, traceId: %s", u.Host, getTraceID(ctx))
+		writeProblem(w, r, http.StatusForbidden, fmt.Sprintf("host %s is not in PROXY_ALLOWED_HOSTS", u.Host))
+		return nil, false
+	}
+	hop := currentHop(ctx)
+	if cfg.MaxHops > 0 && hop >= cfg.MaxHops {
+		writeProblem(w, r, http.StatusLoopDetected, fmt.Sprintf("request already passed through %d hops", hop))
+		return nil, false
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("proxy.target", u.Redacted()), attribute.Int("proxy.hop", hop))
+
+	ctx, cancel := context.WithTimeout(withNextHop(ctx, hop), cfg.Timeout)
+	defer cancel()
+	tt := &timingTrace{}
+	ctx = httptrace.WithClientTrace(ctx, tt.clientTrace())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	if lang := r.Header.Get("Accept-Language"); lang != "" {
+		req.Header.Set("Accept-Language", lang)
+	}
+
+	start := time.Now()
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		span.RecordError(err)
+		log.Printf("[ERROR] Proxy request failed, target: %s, error: %v, traceId: %s", u.Redacted(), err, getTraceID(r.Context()))
+		writeProblem(w, r, status, fmt.Sprintf("downstream request failed: %v", err))
+		return nil, false
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, cfg.MaxBodyBytes+1))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadGateway, fmt.Sprintf("failed to read downstream response: %v", err))
+		return nil, false
+	}
+	timing := tt.timing(start)
+
+	out := &ProxyResponse{
+		Target:      u.Redacted(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Timing:      timing,
+		Hop:         hop,
+		TraceID:     getTraceID(r.Context()),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if int64(len(body)) > cfg.MaxBodyBytes {
+		body, out.Truncated = body[:cfg.MaxBodyBytes], true
+	}
+	if !out.Truncated && strings.Contains(out.ContentType, "json") && json.Valid(body) {
+		out.Body = body
+	} else if len(body) > 0 {
+		out.Body, _ = json.Marshal(string(body))
+	}
+
+	span.SetAttributes(
+		attribute.Int("proxy.status_code", resp.StatusCode),
+		attribute.Float64("proxy.dns_ms", timing.DNSMs),
+		attribute.Float64("proxy.connect_ms", timing.ConnectMs),
+		attribute.Float64("proxy.tls_ms", timing.TLSMs),
+		attribute.Float64("proxy.first_byte_ms", timing.FirstByteMs),
+		attribute.Bool("proxy.reused_conn", timing.ReusedConn),
+	)
+	log.Printf("[INFO] Proxy request, target: %s, status: %d, total: %.1fms, traceId: %s",
+		out.Target, out.Status, timing.TotalMs, getTraceID(r.Context()))
+	return out, true
+}
+
+// proxyHandler makes one traced GET to ?target= and returns the response with a timing breakdown
+func proxyHandler(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&requestCount, 1)
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		writeProblem(w, r, http.StatusBadRequest, "missing target parameter")
+		return
+	}
+	if resp, ok := callDownstream(w, r, target); ok {
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// chainHandler forwards to the first ?via= base URL with the remaining hops, so
+// instances pointed at each other produce one trace spanning every service
+func chainHandler(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&requestCount, 1)
+	ctx := r.Context()
+	response := ChainResponse{
+		Hop:       currentHop(ctx),
+		Message:   greeting(ctx, ""),
+		TraceID:   getTraceID(ctx),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if cfg := currentConfig.Load(); cfg != nil {
+		response.Service = cfg.ServiceName
+	}
+
+	via := r.URL.Query()["via"]
+	if len(via) > 0 {
+		next := strings.TrimSuffix(via[0], "/") + "/api/chain"
+		if rest := via[1:]; len(rest) > 0 {
+			next += "?" + url.Values{"via": rest}.Encode()
+		}
+		downstream, ok := callDownstream(w, r, next)
+		if !ok {
+			return
+		}
+		response.Downstream = downstream
+	}
+	writeJSON(w, http.StatusOK, response)
+}
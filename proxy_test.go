@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestProxyAllowed(t *testing.T) {
+	cfg := &ProxyConfig{AllowedHosts: []string{"downstream", "127.0.0.1:8001", "*.svc.local"}}
+	tests := []struct {
+		target  string
+		allowed bool
+	}{
+		{"http://downstream/api/hello", true},
+		{"https://DOWNSTREAM:8443/", true},
+		{"http://127.0.0.1:8001/api/hello", true},
+		{"http://127.0.0.1:9000/admin/config", false},
+		{"http://api.svc.local/", true},
+		{"http://svc.local/", false},
+		{"http://evil.com/?downstream", false},
+		{"file:///etc/passwd", false},
+		{"gopher://downstream/", false},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.target)
+		if got := cfg.allowed(u); got != tt.allowed {
+			t.Errorf("%s: got %v want %v", tt.target, got, tt.allowed)
+		}
+	}
+}
+
+// setupProxy installs a propagating tracer and starts n instances of the proxy endpoints
+func setupProxy(t *testing.T, n int) []*httptest.Server {
+	t.Helper()
+	oldTP, oldProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(oldTP)
+		otel.SetTextMapPropagator(oldProp)
+	})
+
+	var servers []*httptest.Server
+	var hosts []string
+	for i := 0; i < n; i++ {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/proxy", proxyHandler)
+		mux.HandleFunc("/api/chain", chainHandler)
+		srv := httptest.NewServer(otelhttp.NewHandler(mux, "test"))
+		t.Cleanup(srv.Close)
+		servers = append(servers, srv)
+		hosts = append(hosts, strings.TrimPrefix(srv.URL, "http://"))
+	}
+
+	cfg := loadConfig().Proxy
+	cfg.AllowedHosts = hosts
+	old := proxyConfig.Load()
+	proxyConfig.Store(&cfg)
+	t.Cleanup(func() { proxyConfig.Store(old) })
+	return servers
+}
+
+func TestProxyHandler(t *testing.T) {
+	servers := setupProxy(t, 1)
+
+	var got http.Header
+	downstream := httptest.NewServer(otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		if hop := currentHop(r.Context()); hop != 1 {
+			t.Errorf("downstream saw hop %d, want 1", hop)
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"hello": "downstream"})
+	}), "downstream"))
+	defer downstream.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	cfg := *proxyConfig.Load()
+	cfg.AllowedHosts = append(cfg.AllowedHosts, strings.TrimPrefix(downstream.URL, "http://"), strings.TrimPrefix(closed.URL, "http://"))
+	proxyConfig.Store(&cfg)
+
+	resp, err := http.Get(servers[0].URL + "/api/proxy?target=" + url.QueryEscape(downstream.URL+"/anything"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var pr ProxyResponse
+	json.NewDecoder(resp.Body).Decode(&pr)
+
+	if resp.StatusCode != http.StatusOK || pr.Status != http.StatusOK {
+		t.Fatalf("unexpected status: %d, downstream %d", resp.StatusCode, pr.Status)
+	}
+	if string(pr.Body) != `{"hello":"downstream"}` {
+		t.Errorf("downstream JSON was not embedded: %s", pr.Body)
+	}
+	if pr.Timing.ConnectMs <= 0 || pr.Timing.FirstByteMs <= 0 || pr.Timing.TotalMs < pr.Timing.FirstByteMs {
+		t.Errorf("unexpected timing: %+v", pr.Timing)
+	}
+	if tp := got.Get("Traceparent"); pr.TraceID == "" || !strings.Contains(tp, pr.TraceID) {
+		t.Errorf("traceparent %q does not carry trace %s", tp, pr.TraceID)
+	}
+	if bag, _ := baggage.Parse(got.Get("Baggage")); bag.Member(hopBaggageKey).Value() != "1" {
+		t.Errorf("unexpected baggage: %q", got.Get("Baggage"))
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"missing target", "", http.StatusBadRequest},
+		{"relative target", "/api/hello", http.StatusBadRequest},
+		{"host not allowed", "http://example.com/", http.StatusForbidden},
+		{"downstream error is reported", servers[0].URL + "/missing", http.StatusOK},
+		{"unreachable", closed.URL, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			proxyHandler(rr, httptest.NewRequest("GET", "/api/proxy?target="+url.QueryEscape(tt.target), nil))
+			if rr.Code != tt.status {
+				t.Errorf("unexpected status: got %d want %d: %s", rr.Code, tt.status, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestChainHandler(t *testing.T) {
+	servers := setupProxy(t, 3)
+
+	chainURL := servers[0].URL + "/api/chain?" + url.Values{"via": {servers[1].URL, servers[2].URL}}.Encode()
+	resp, err := http.Get(chainURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var chain ChainResponse
+	json.NewDecoder(resp.Body).Decode(&chain)
+
+	// Every hop must report the same trace and one more hop than its caller
+	traceID := chain.TraceID
+	for hop := 0; hop < 3; hop++ {
+		if chain.Hop != hop || chain.TraceID != traceID || traceID == "" {
+			t.Fatalf("hop %d: unexpected response %+v (trace %s)", hop, chain, traceID)
+		}
+		if hop == 2 {
+			if chain.Downstream != nil {
+				t.Errorf("last hop should not call further")
+			}
+			break
+		}
+		if chain.Downstream == nil {
+			t.Fatalf("hop %d has no downstream response", hop)
+		}
+		next := ChainResponse{}
+		if err := json.Unmarshal(chain.Downstream.Body, &next); err != nil {
+			t.Fatal(err)
+		}
+		chain = next
+	}
+
+	// A chain longer than MaxHops is cut off
+	cfg := *proxyConfig.Load()
+	cfg.MaxHops = 1
+	proxyConfig.Store(&cfg)
+	resp, err = http.Get(chainURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	chain = ChainResponse{}
+	json.NewDecoder(resp.Body).Decode(&chain)
+	if chain.Downstream == nil || chain.Downstream.Status != http.StatusLoopDetected {
+		t.Errorf("second hop should refuse to go further: %+v", chain.Downstream)
+	}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/chain?via="+url.QueryEscape(servers[1].URL), nil)
+	member, _ := baggage.NewMember(hopBaggageKey, "1")
+	bag, _ := baggage.New(member)
+	chainHandler(rr, req.WithContext(baggage.ContextWithBaggage(req.Context(), bag)))
+	if rr.Code != http.StatusLoopDetected {
+		t.Errorf("hop limit not enforced: %d", rr.Code)
+	}
+}
+
+func TestProxyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   func(*ProxyConfig)
+		valid bool
+	}{
+		{"defaults", func(*ProxyConfig) {}, true},
+		{"zero timeout", func(c *ProxyConfig) { c.Timeout = 0 }, false},
+		{"negative timeout", func(c *ProxyConfig) { c.Timeout = -time.Second }, false},
+		{"zero max body bytes", func(c *ProxyConfig) { c.MaxBodyBytes = 0 }, false},
+		{"negative max body bytes", func(c *ProxyConfig) { c.MaxBodyBytes = -1 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			tt.cfg(&cfg.Proxy)
+			if err := cfg.Proxy.validate(); (err == nil) != tt.valid {
+				t.Errorf("validate() = %v, want valid %t", err, tt.valid)
+			}
+			if err := applyConfig(cfg); (err == nil) != tt.valid {
+				t.Errorf("applyConfig() = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+	applyConfig(loadConfig())
+}
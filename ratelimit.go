@@ -0,0 +1,212 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const maxTrackedBuckets = 10000
+
+// tokenBucket refills at policy.RequestsPerSecond up to policy.Burst tokens
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter applies token-bucket policies per route and client
+type rateLimiter struct {
+	cfg atomic.Pointer[RateLimitConfig]
+
+	mu        sync.Mutex
+	buckets   map[string]*list.Element // of *tokenBucket
+	recent    *list.List               // most recently used first
+	evicted   int64
+	throttled map[string]int64
+}
+
+type RateLimitMetrics struct {
+	Enabled          bool             `json:"enabled"`
+	TrackedClients   int              `json:"trackedClients"`
+	Evicted          int64            `json:"evicted"` // buckets dropped to stay under the tracking cap
+	Throttled        int64            `json:"throttled"`
+	ThrottledByRoute map[string]int64 `json:"throttledByRoute,omitempty"`
+}
+
+var rateLimits = newRateLimiter(RateLimitConfig{})
+
+func newRateLimiter(cfg RateLimitConfig) *rateLimiter {
+	rl := &rateLimiter{
+		buckets:   make(map[string]*list.Element),
+		recent:    list.New(),
+		throttled: make(map[string]int64),
+	}
+	rl.setConfig(cfg)
+	return rl
+}
+
+// setConfig swaps the policies; existing buckets are dropped so new limits apply immediately
+func (rl *rateLimiter) setConfig(cfg RateLimitConfig) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.cfg.Store(&cfg)
+	rl.buckets = make(map[string]*list.Element)
+	rl.recent.Init()
+}
+
+// policyFor returns the policy for a path and the name it is tracked under:
+// the path for routes with their own policy, "*" for the default. Probes are never limited.
+func (cfg *RateLimitConfig) policyFor(path string) (RateLimitPolicy, string, bool) {
+	if !cfg.Enabled || isProbe(path) {
+		return RateLimitPolicy{}, "", false
+	}
+	policy, ok := cfg.Routes[path]
+	name := path
+	if !ok {
+		policy, name = cfg.Default, "*"
+	}
+	return policy, name, policy.RequestsPerSecond > 0 && policy.Burst > 0
+}
+
+// clientKey identifies the caller by IP, authenticated principal or a configured
+// header. Unknown API keys and bad tokens count against the client IP, so inventing
+// a new key per request does not buy a fresh bucket.
+func (cfg *RateLimitConfig) clientKey(r *http.Request) string {
+	switch cfg.KeyBy {
+	case "apikey":
+		if a := auth.Load(); a.cfg.Enabled {
+			if p, err := a.authenticate(r); err == nil {
+				return "principal:" + p.Method + ":" + p.Subject
+			}
+		}
+	case "header":
+		if v := r.Header.Get(cfg.Header); cfg.Header != "" && v != "" {
+			return "header:" + v
+		}
+	}
+	return "ip:" + clientIP(r, cfg.TrustForwardedFor)
+}
+
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// take consumes a token from the bucket for key, reporting whether one was available
+// and how many remain. Once maxTrackedBuckets clients are tracked, a new client
+// evicts the least recently used bucket, so rotating addresses or keys cannot grow
+// the map without bound.
+func (rl *rateLimiter) take(key string, policy RateLimitPolicy, now time.Time) (bool, float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	var b *tokenBucket
+	if e, ok := rl.buckets[key]; ok {
+		rl.recent.MoveToFront(e)
+		b = e.Value.(*tokenBucket)
+	} else {
+		if len(rl.buckets) >= maxTrackedBuckets {
+			oldest := rl.recent.Back()
+			delete(rl.buckets, rl.recent.Remove(oldest).(*tokenBucket).key)
+			rl.evicted++
+		}
+		b = &tokenBucket{key: key, tokens: float64(policy.Burst), last: now}
+		rl.buckets[key] = rl.recent.PushFront(b)
+	}
+	b.tokens = math.Min(float64(policy.Burst), b.tokens+now.Sub(b.last).Seconds()*policy.RequestsPerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, b.tokens
+	}
+	b.tokens--
+	return true, b.tokens
+}
+
+func (rl *rateLimiter) metrics() RateLimitMetrics {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	m := RateLimitMetrics{
+		Enabled:          rl.cfg.Load().Enabled,
+		TrackedClients:   len(rl.buckets),
+		Evicted:          rl.evicted,
+		ThrottledByRoute: make(map[string]int64, len(rl.throttled)),
+	}
+	for route, n := range rl.throttled {
+		m.Throttled += n
+		m.ThrottledByRoute[route] = n
+	}
+	return m
+}
+
+// limitRate enforces the per-client policies and sets the RateLimit-* headers
+func limitRate(rl *rateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy, tokens, limited, allowed := rl.admit(r)
+		if !limited {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reset := math.Ceil((float64(policy.Burst) - tokens) / policy.RequestsPerSecond)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(reset)))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Burst, int(math.Ceil(float64(policy.Burst)/policy.RequestsPerSecond))))
+
+		if !allowed {
+			retryAfter := math.Ceil((1 - tokens) / policy.RequestsPerSecond)
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+			writeProblem(w, r, http.StatusTooManyRequests, policy.exceeded())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// admit takes a token for r from its client's bucket. limited is false when no
+// policy applies; throttled requests are counted, logged and recorded on the span.
+func (rl *rateLimiter) admit(r *http.Request) (policy RateLimitPolicy, tokens float64, limited, allowed bool) {
+	cfg := rl.cfg.Load()
+	policy, name, limited := cfg.policyFor(r.URL.Path)
+	if !limited {
+		return policy, 0, false, true
+	}
+
+	allowed, tokens = rl.take(name+"|"+cfg.clientKey(r), policy, time.Now())
+	if !allowed {
+		rl.mu.Lock()
+		rl.throttled[name]++
+		rl.mu.Unlock()
+
+		span := trace.SpanFromContext(r.Context())
+		span.AddEvent("ratelimit.throttled", trace.WithAttributes(attribute.String("ratelimit.key_by", cfg.KeyBy)))
+		log.Printf("[WARN] Rate limit exceeded, path: %s, client: %s, traceId: %s",
+			r.URL.Path, clientIP(r, cfg.TrustForwardedFor), getTraceID(r.Context()))
+	}
+	return policy, tokens, true, allowed
+}
+
+func (p RateLimitPolicy) exceeded() string {
+	return fmt.Sprintf("rate limit of %g requests per second (burst %d) exceeded", p.RequestsPerSecond, p.Burst)
+}
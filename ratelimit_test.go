@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLimitRate(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	writeFile(t, keysFile, `[{"key":"a","subject":"client-a"},{"key":"b","subject":"client-b"}]`)
+	a, err := newAuthenticator(AuthConfig{Enabled: true, APIKeysFile: keysFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := auth.Load()
+	auth.Store(a)
+	t.Cleanup(func() { auth.Store(old) })
+
+	rl := newRateLimiter(RateLimitConfig{
+		Enabled: true,
+		KeyBy:   "apikey",
+		Default: RateLimitPolicy{RequestsPerSecond: 0.001, Burst: 2},
+		Routes: map[string]RateLimitPolicy{
+			"/api/echo": {RequestsPerSecond: 0.001, Burst: 1},
+		},
+	})
+	handler := limitRate(rl, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name      string
+		path      string
+		apiKey    string
+		expected  int
+		remaining string
+	}{
+		{"first", "/api/random", "a", http.StatusOK, "1"},
+		{"second", "/api/random", "a", http.StatusOK, "0"},
+		{"throttled", "/api/random", "a", http.StatusTooManyRequests, "0"},
+		{"other client", "/api/random", "b", http.StatusOK, "1"},
+		{"route policy", "/api/echo", "a", http.StatusOK, "0"},
+		{"route throttled", "/api/echo", "a", http.StatusTooManyRequests, "0"},
+		{"probe", "/health", "a", http.StatusOK, ""},
+		// Unknown keys all share the bucket of the client IP
+		{"made-up key", "/api/random", "x1", http.StatusOK, "1"},
+		{"another made-up key", "/api/random", "x2", http.StatusOK, "0"},
+		{"made-up keys throttled", "/api/random", "x3", http.StatusTooManyRequests, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("X-API-Key", tt.apiKey)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+			if remaining := rr.Header().Get("RateLimit-Remaining"); remaining != tt.remaining {
+				t.Errorf("unexpected RateLimit-Remaining: got %q want %q", remaining, tt.remaining)
+			}
+			if tt.expected == http.StatusTooManyRequests {
+				if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+					t.Errorf("unexpected content type: got %q", ct)
+				}
+				var problem Problem
+				if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil || problem.Status != http.StatusTooManyRequests {
+					t.Errorf("unexpected problem response: %s", rr.Body.String())
+				}
+			}
+		})
+	}
+
+	if m := rl.metrics(); m.Throttled != 3 || m.ThrottledByRoute["/api/echo"] != 1 {
+		t.Errorf("unexpected rate limit metrics: %+v", m)
+	}
+}
+
+func TestReloadConfigAppliesRateLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"rateLimit":{"enabled":true,"default":{"requestsPerSecond":5,"burst":7}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	defer applyConfig(loadConfig())
+	// The failed reload below marks the app unready for every later test
+	defer healthState.Delete("config")
+
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := rateLimits.cfg.Load()
+	if !cfg.Enabled || cfg.Default.Burst != 7 {
+		t.Errorf("rate limits not reloaded: %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte(`{not json`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err == nil {
+		t.Error("expected an error for an invalid config file")
+	}
+	if rateLimits.cfg.Load().Default.Burst != 7 {
+		t.Error("failed reload should keep the current config")
+	}
+}
+
+func TestRateLimiterEvictsLeastRecentlyUsed(t *testing.T) {
+	rl := newRateLimiter(RateLimitConfig{Enabled: true})
+	policy := RateLimitPolicy{RequestsPerSecond: 0.001, Burst: 1}
+	now := time.Now()
+	for i := 0; i < maxTrackedBuckets; i++ {
+		rl.take(fmt.Sprintf("*|ip:%d", i), policy, now)
+	}
+	// Touching client 0 makes client 1 the least recently used
+	if ok, _ := rl.take("*|ip:0", policy, now); ok {
+		t.Fatal("client 0 should have used its only token")
+	}
+	rl.take("*|ip:new", policy, now)
+
+	if m := rl.metrics(); m.TrackedClients != maxTrackedBuckets || m.Evicted != 1 {
+		t.Errorf("got %d tracked and %d evicted, want %d and 1", m.TrackedClients, m.Evicted, maxTrackedBuckets)
+	}
+	if ok, _ := rl.take("*|ip:0", policy, now); ok {
+		t.Error("client 0 was evicted instead of the least recently used client")
+	}
+	if ok, _ := rl.take("*|ip:1", policy, now); !ok {
+		t.Error("client 1 should have been evicted and start with a full bucket")
+	}
+}
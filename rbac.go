@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// anonymousSubject is the subject used for bindings of unauthenticated callers
+const anonymousSubject = "anonymous"
+
+var rbac atomic.Pointer[RBACConfig]
+
+func init() {
+	rbac.Store(&RBACConfig{})
+}
+
+type AuthorizationMetrics struct {
+	Enabled         bool             `json:"enabled"`
+	Denied          int64            `json:"denied"`
+	DeniedByRoute   map[string]int64 `json:"deniedByRoute,omitempty"`
+	DeniedBySubject map[string]int64 `json:"deniedBySubject,omitempty"`
+}
+
+var denials = struct {
+	sync.Mutex
+	byRoute   map[string]int64
+	bySubject map[string]int64
+}{byRoute: make(map[string]int64), bySubject: make(map[string]int64)}
+
+// validate requires an admin role, which must be defined when RBAC is enabled
+func (cfg *RBACConfig) validate() error {
+	if cfg.AdminRole == "" {
+		return errors.New("rbac: admin role must not be empty")
+	}
+	if _, ok := cfg.Roles[cfg.AdminRole]; cfg.Enabled && !ok {
+		return fmt.Errorf("rbac: admin role %q is not defined", cfg.AdminRole)
+	}
+	return nil
+}
+
+// matches reports whether the permission covers the method and path.
+// A path ending in "*" matches by prefix, a lone "*" matches everything.
+func (p Permission) matches(method, path string) bool {
+	methodOK := false
+	for _, m := range p.Methods {
+		if m == "*" || strings.EqualFold(m, method) {
+			methodOK = true
+			break
+		}
+	}
+	if !methodOK {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(p.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return p.Path == path
+}
+
+// rolesFor combines the roles carried by the principal with the configured bindings
+func (cfg *RBACConfig) rolesFor(p *Principal) []string {
+	subject := anonymousSubject
+	var roles []string
+	if p != nil {
+		subject = p.Subject
+		roles = append(roles, p.Roles...)
+	}
+	return append(roles, cfg.Bindings[subject]...)
+}
+
+// allowed reports whether any of the roles grants method on path
+func (cfg *RBACConfig) allowed(roles []string, method, path string) bool {
+	for _, role := range roles {
+		for _, perm := range cfg.Roles[role] {
+			if perm.matches(method, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authorize enforces the role bindings on requests under prefix, after authentication
+func authorize(prefix string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := rbac.Load()
+		if !cfg.Enabled || !strings.HasPrefix(r.URL.Path, prefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if code, err := cfg.permit(r); err != nil {
+			if code == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="demo-app"`)
+			}
+			writeProblem(w, r, code, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// permit checks the principal in the context of r against the bindings and records
+// denials. It returns the status and reason to reject the request with.
+func (cfg *RBACConfig) permit(r *http.Request) (int, error) {
+	p := principalFromContext(r.Context())
+	roles := cfg.rolesFor(p)
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(attribute.StringSlice("enduser.role", roles))
+	if cfg.allowed(roles, r.Method, r.URL.Path) {
+		return http.StatusOK, nil
+	}
+
+	subject := anonymousSubject
+	if p != nil {
+		subject = p.Subject
+	}
+	route := r.URL.Path
+	denials.Lock()
+	if _, ok := denials.byRoute[route]; !ok && len(denials.byRoute) >= 1000 {
+		route = "other"
+	}
+	denials.byRoute[route]++
+	denials.bySubject[subject]++
+	denials.Unlock()
+
+	span.AddEvent("authz.denied", trace.WithAttributes(
+		attribute.String("enduser.id", subject),
+		attribute.String("http.method", r.Method),
+	))
+	span.SetStatus(codes.Error, "access denied")
+	log.Printf("[WARN] Access denied by RBAC, method: %s, path: %s, subject: %s, roles: %v, traceId: %s",
+		r.Method, r.URL.Path, subject, roles, getTraceID(r.Context()))
+
+	if p == nil {
+		return http.StatusUnauthorized, errors.New("authentication required")
+	}
+	return http.StatusForbidden, errors.New("subject " + subject + " may not " + r.Method + " " + r.URL.Path)
+}
+
+func authorizationMetrics() AuthorizationMetrics {
+	denials.Lock()
+	defer denials.Unlock()
+	m := AuthorizationMetrics{
+		Enabled:         rbac.Load().Enabled,
+		DeniedByRoute:   make(map[string]int64, len(denials.byRoute)),
+		DeniedBySubject: make(map[string]int64, len(denials.bySubject)),
+	}
+	for route, n := range denials.byRoute {
+		m.Denied += n
+		m.DeniedByRoute[route] = n
+	}
+	for subject, n := range denials.bySubject {
+		m.DeniedBySubject[subject] = n
+	}
+	return m
+}
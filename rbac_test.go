@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	cfg := loadConfig().RBAC
+	cfg.Enabled = true
+	cfg.Bindings = map[string][]string{
+		"alice":          {"operator"},
+		"root":           {"admin"},
+		anonymousSubject: {"viewer"},
+	}
+	old := rbac.Load()
+	rbac.Store(&cfg)
+	defer rbac.Store(old)
+
+	handler := authorize("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		subject  string
+		roles    []string
+		expected int
+	}{
+		{"anonymous read", "GET", "/api/hello", "", nil, http.StatusOK},
+		{"anonymous write", "POST", "/api/echo", "", nil, http.StatusUnauthorized},
+		{"operator write api", "POST", "/api/echo", "alice", nil, http.StatusOK},
+		{"operator write admin", "PUT", "/admin/loglevel", "alice", nil, http.StatusForbidden},
+		{"admin write admin", "PUT", "/admin/loglevel", "root", nil, http.StatusOK},
+		{"role from token", "POST", "/admin/stress/cpu", "bob", []string{"admin"}, http.StatusOK},
+		{"unbound subject", "GET", "/admin/config", "mallory", nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.subject != "" {
+				p := &Principal{Subject: tt.subject, Method: "jwt", Roles: tt.roles}
+				req = req.WithContext(context.WithValue(req.Context(), principalKey{}, p))
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+
+	m := authorizationMetrics()
+	if m.DeniedBySubject["mallory"] != 1 || m.DeniedBySubject["alice"] != 1 {
+		t.Errorf("unexpected authorization metrics: %+v", m)
+	}
+}
+
+func TestPermissionMatches(t *testing.T) {
+	tests := []struct {
+		perm     Permission
+		method   string
+		path     string
+		expected bool
+	}{
+		{Permission{Methods: []string{"GET"}, Path: "/api/hello"}, "GET", "/api/hello", true},
+		{Permission{Methods: []string{"GET"}, Path: "/api/hello"}, "POST", "/api/hello", false},
+		{Permission{Methods: []string{"*"}, Path: "/admin/*"}, "DELETE", "/admin/stress", true},
+		{Permission{Methods: []string{"*"}, Path: "/admin/*"}, "GET", "/api/hello", false},
+		{Permission{Methods: []string{"get"}, Path: "*"}, "GET", "/anything", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.perm.matches(tt.method, tt.path); got != tt.expected {
+			t.Errorf("%+v matches %s %s: got %v want %v", tt.perm, tt.method, tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestRBACConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(*RBACConfig)
+		valid bool
+	}{
+		{"default", func(*RBACConfig) {}, true},
+		{"enabled", func(c *RBACConfig) { c.Enabled = true }, true},
+		{"empty admin role", func(c *RBACConfig) { c.AdminRole = "" }, false},
+		{"undefined admin role", func(c *RBACConfig) { c.Enabled, c.AdminRole = true, "sre" }, false},
+		{"undefined role while disabled", func(c *RBACConfig) { c.AdminRole = "sre" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig().RBAC
+			tt.edit(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.valid {
+				t.Errorf("got %v want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SamplingRule samples root spans matching Route at Ratio. Route is matched
+// against the request path, or against the span name for spans started outside
+// an HTTP request such as gRPC calls; a trailing "*" matches by prefix.
+type SamplingRule struct {
+	Route string  `json:"route"`
+	Ratio float64 `json:"ratio"`
+}
+
+func (rule SamplingRule) matches(route string) bool {
+	if prefix, ok := strings.CutSuffix(rule.Route, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return rule.Route == route
+}
+
+// validateSamplingRules rejects rules without a route or with a ratio outside [0, 1]
+func validateSamplingRules(rules []SamplingRule) error {
+	for _, rule := range rules {
+		if rule.Route == "" {
+			return fmt.Errorf("sampling rule without a route")
+		}
+		if rule.Ratio < 0 || rule.Ratio > 1 {
+			return fmt.Errorf("sampling rule for %s: ratio must be between 0 and 1", rule.Route)
+		}
+	}
+	return nil
+}
+
+// ratioSampler is a TraceIDRatioBased sampler whose ratio and per-route rules can
+// be changed at runtime. The first matching rule wins, otherwise the ratio applies.
+type ratioSampler struct {
+	current atomic.Pointer[ratioState]
+}
+
+type ratioState struct {
+	ratio    float64
+	sampler  sdktrace.Sampler
+	rules    []SamplingRule
+	samplers []sdktrace.Sampler
+}
+
+var sampler = newRatioSampler(1.0)
+
+func newRatioSampler(ratio float64) *ratioSampler {
+	s := &ratioSampler{}
+	s.SetRatio(ratio)
+	return s
+}
+
+func (s *ratioSampler) store(ratio float64, rules []SamplingRule) {
+	ratio = math.Max(0, math.Min(1, ratio))
+	state := &ratioState{ratio: ratio, sampler: sdktrace.TraceIDRatioBased(ratio), rules: rules}
+	for _, rule := range rules {
+		state.samplers = append(state.samplers, sdktrace.TraceIDRatioBased(rule.Ratio))
+	}
+	s.current.Store(state)
+}
+
+// SetRatio replaces the default sampling ratio, clamped to [0, 1], keeping the rules
+func (s *ratioSampler) SetRatio(ratio float64) {
+	var rules []SamplingRule
+	if state := s.current.Load(); state != nil {
+		rules = state.rules
+	}
+	s.store(ratio, rules)
+}
+
+// SetRules replaces the per-route rules, keeping the default ratio
+func (s *ratioSampler) SetRules(rules []SamplingRule) {
+	s.store(s.Ratio(), append([]SamplingRule(nil), rules...))
+}
+
+func (s *ratioSampler) Ratio() float64 {
+	return s.current.Load().ratio
+}
+
+func (s *ratioSampler) Rules() []SamplingRule {
+	return append([]SamplingRule{}, s.current.Load().rules...)
+}
+
+func (s *ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	state := s.current.Load()
+	route, ok := p.ParentContext.Value(samplingRouteKey{}).(string)
+	if !ok {
+		route = p.Name
+	}
+	for i, rule := range state.rules {
+		if rule.matches(route) {
+			return state.samplers[i].ShouldSample(p)
+		}
+	}
+	return state.sampler.ShouldSample(p)
+}
+
+func (s *ratioSampler) Description() string {
+	state := s.current.Load()
+	return fmt.Sprintf("RuntimeRatioSampler{%g, %d rules}", state.ratio, len(state.rules))
+}
+
+type samplingRouteKey struct{}
+
+// withSamplingRoute exposes the request path to the sampler. It must wrap the
+// otelhttp handler, which names every server span after the listener.
+func withSamplingRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), samplingRouteKey{}, r.URL.Path)))
+	})
+}
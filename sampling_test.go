@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRatioSamplerRules(t *testing.T) {
+	s := newRatioSampler(0)
+	s.SetRules([]SamplingRule{{Route: "/api/hello", Ratio: 1}, {Route: "/api/*", Ratio: 0}, {Route: "demo.v1.*", Ratio: 1}})
+	traceID := trace.TraceID{1}
+
+	tests := []struct {
+		name     string
+		route    string
+		span     string
+		expected sdktrace.SamplingDecision
+	}{
+		{"exact route", "/api/hello", "demo-app", sdktrace.RecordAndSample},
+		{"prefix route", "/api/random", "demo-app", sdktrace.Drop},
+		{"default ratio", "/version", "demo-app", sdktrace.Drop},
+		{"span name", "", "demo.v1.DemoService/Hello", sdktrace.RecordAndSample},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.route != "" {
+				ctx = context.WithValue(ctx, samplingRouteKey{}, tt.route)
+			}
+			got := s.ShouldSample(sdktrace.SamplingParameters{ParentContext: ctx, TraceID: traceID, Name: tt.span}).Decision
+			if got != tt.expected {
+				t.Errorf("got %v want %v", got, tt.expected)
+			}
+		})
+	}
+
+	s.SetRatio(1)
+	if len(s.Rules()) != 3 {
+		t.Errorf("SetRatio dropped the rules: %+v", s.Rules())
+	}
+	if err := validateSamplingRules([]SamplingRule{{Route: "/x", Ratio: 1.5}}); err == nil {
+		t.Error("a ratio above 1 should be rejected")
+	}
+	if err := validateSamplingRules([]SamplingRule{{Ratio: 1}}); err == nil {
+		t.Error("a rule without a route should be rejected")
+	}
+}
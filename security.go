@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// cspNoncePlaceholder is replaced with the per-request nonce in the configured policy
+const cspNoncePlaceholder = "{nonce}"
+
+var securityConfig atomic.Pointer[SecurityHeadersConfig]
+
+func init() {
+	securityConfig.Store(&SecurityHeadersConfig{})
+}
+
+type cspNonceKey struct{}
+
+// cspNonce returns the nonce that inline <style> and <script> elements must carry
+func cspNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// newNonce uses the URL-safe alphabet so html/template emits it without entity escaping
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// secureHeaders adds HSTS, CSP and the other browser hardening headers to every response
+func secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := securityConfig.Load()
+		if !cfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if cfg.FrameOptions != "" {
+			h.Set("X-Frame-Options", cfg.FrameOptions)
+		}
+		if cfg.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+		}
+		if cfg.PermissionsPolicy != "" {
+			h.Set("Permissions-Policy", cfg.PermissionsPolicy)
+		}
+		// Browsers ignore HSTS on plain HTTP, so only send it on secure connections
+		if cfg.HSTSMaxAge > 0 && (r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https") {
+			value := "max-age=" + strconv.Itoa(cfg.HSTSMaxAge)
+			if cfg.HSTSIncludeSubdomains {
+				value += "; includeSubDomains"
+			}
+			h.Set("Strict-Transport-Security", value)
+		}
+
+		if cfg.ContentSecurityPolicy != "" {
+			nonce := newNonce()
+			header := "Content-Security-Policy"
+			if cfg.CSPReportOnly {
+				header = "Content-Security-Policy-Report-Only"
+			}
+			h.Set(header, strings.ReplaceAll(cfg.ContentSecurityPolicy, cspNoncePlaceholder, nonce))
+			r = r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSPViolation is the body of a legacy application/csp-report violation report
+type CSPViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"source-file,omitempty"`
+	LineNumber         int    `json:"line-number,omitempty"`
+}
+
+// parseCSPReports accepts both the legacy report-uri format and the Reporting API format
+func parseCSPReports(contentType string, body []byte) ([]CSPViolation, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/reports+json" {
+		var reports []struct {
+			Type string `json:"type"`
+			Body struct {
+				DocumentURL        string `json:"documentURL"`
+				BlockedURL         string `json:"blockedURL"`
+				EffectiveDirective string `json:"effectiveDirective"`
+				Disposition        string `json:"disposition"`
+				SourceFile         string `json:"sourceFile"`
+				LineNumber         int    `json:"lineNumber"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+		var violations []CSPViolation
+		for _, rep := range reports {
+			if rep.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, CSPViolation{
+				DocumentURI:        rep.Body.DocumentURL,
+				BlockedURI:         rep.Body.BlockedURL,
+				ViolatedDirective:  rep.Body.EffectiveDirective,
+				EffectiveDirective: rep.Body.EffectiveDirective,
+				Disposition:        rep.Body.Disposition,
+				SourceFile:         rep.Body.SourceFile,
+				LineNumber:         rep.Body.LineNumber,
+			})
+		}
+		return violations, nil
+	}
+
+	var legacy struct {
+		Report CSPViolation `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	return []CSPViolation{legacy.Report}, nil
+}
+
+// cspReportHandler collects violation reports sent by browsers
+func cspReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeProblem(w, r, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	violations, err := parseCSPReports(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "invalid CSP report: "+err.Error())
+		return
+	}
+
+	span := trace.SpanFromContext(r.Context())
+	for _, v := range violations {
+		directive := v.EffectiveDirective
+		if directive == "" {
+			directive = v.ViolatedDirective
+		}
+		span.AddEvent("csp.violation", trace.WithAttributes(
+			attribute.String("csp.document_uri", v.DocumentURI),
+			attribute.String("csp.blocked_uri", v.BlockedURI),
+			attribute.String("csp.directive", directive),
+			attribute.String("csp.disposition", v.Disposition),
+		))
+		// Every field comes from the client, quoting keeps a newline from forging log lines
+		log.Printf("[WARN] CSP violation, directive: %q, blocked: %q, document: %q, disposition: %q, traceId: %s",
+			directive, v.BlockedURI, v.DocumentURI, v.Disposition, getTraceID(r.Context()))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	cfg := loadConfig().Security
+	old := securityConfig.Load()
+	securityConfig.Store(&cfg)
+	defer securityConfig.Store(old)
+
+	handler := secureHeaders(http.HandlerFunc(rootHandler))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	for header, expected := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+	} {
+		if got := rr.Header().Get(header); got != expected {
+			t.Errorf("unexpected %s: got %q want %q", header, got, expected)
+		}
+	}
+	if got := rr.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS sent over plain HTTP: %q", got)
+	}
+
+	csp := rr.Header().Get("Content-Security-Policy")
+	_, rest, ok := strings.Cut(csp, "'nonce-")
+	if !ok {
+		t.Fatalf("CSP has no nonce: %q", csp)
+	}
+	nonce, _, _ := strings.Cut(rest, "'")
+	if !strings.Contains(rr.Body.String(), `<style nonce="`+nonce+`">`) {
+		t.Errorf("root page style does not carry the CSP nonce %q", nonce)
+	}
+
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, req)
+	if rr2.Header().Get("Content-Security-Policy") == csp {
+		t.Error("nonce was reused across requests")
+	}
+
+	cfg.CSPReportOnly = true
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Security-Policy") != "" || rr.Header().Get("Content-Security-Policy-Report-Only") == "" {
+		t.Errorf("report-only mode set the wrong header: %v", rr.Header())
+	}
+	if got := rr.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("unexpected HSTS: %q", got)
+	}
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"legacy report", "POST", "application/csp-report",
+			`{"csp-report":{"document-uri":"http://localhost/","blocked-uri":"inline","violated-directive":"style-src"}}`, http.StatusNoContent},
+		{"reporting api", "POST", "application/reports+json",
+			`[{"type":"csp-violation","body":{"documentURL":"http://localhost/","blockedURL":"inline","effectiveDirective":"script-src-elem"}}]`, http.StatusNoContent},
+		{"invalid json", "POST", "application/csp-report", `{`, http.StatusBadRequest},
+		{"wrong method", "GET", "", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/csp-report", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			cspReportHandler(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCSPReportHandlerQuotesClientFields(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	body := `{"csp-report":{"document-uri":"http://localhost/\n[ERROR] forged","blocked-uri":"inline\r\nx","violated-directive":"style-src"}}`
+	req := httptest.NewRequest("POST", "/csp-report", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/csp-report")
+	cspReportHandler(httptest.NewRecorder(), req)
+
+	out := strings.TrimSuffix(buf.String(), "\n")
+	if strings.Contains(out, "\n") || strings.Contains(out, "\r") {
+		t.Errorf("client fields broke the log line: %q", out)
+	}
+	if !strings.Contains(out, `document: "http://localhost/\n[ERROR] forged"`) {
+		t.Errorf("got %q want the quoted document URI", out)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+)
+
+// drainServers stops the listeners and waits until ctx is done for in-flight
+// requests to finish. The drain is announced first: /ready turns unready and
+// event streams receive a "draining" lifecycle event before they are closed,
+// so clients reconnect to another instance.
+func drainServers(ctx context.Context, reason string, servers []*http.Server, grpcSrv *grpc.Server) {
+	log.Printf("[INFO] Draining connections, reason: %s", reason)
+	publishLifecycle("draining", reason)
+	setHealth("http", "fail", "draining")
+
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		// Event streams never finish on their own
+		srv.RegisterOnShutdown(events.disconnectAll)
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("[WARN] Server %s did not drain in time: %v", srv.Addr, err)
+				srv.Close()
+			}
+		}(srv)
+	}
+	if grpcSrv != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				grpcSrv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				log.Printf("[WARN] gRPC server did not drain in time: %v", ctx.Err())
+				grpcSrv.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+	log.Printf("[INFO] Connections drained")
+}
+
+// flushTelemetry closes the topology simulator, whose virtual services export
+// through their own providers, and then flushes the tracer. Either may be nil.
+func flushTelemetry(ctx context.Context, sim *simulator, tp *sdktrace.TracerProvider) {
+	if sim != nil {
+		sim.close()
+	}
+	if tp != nil {
+		if err := tp.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down tracer: %v", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrainServers(t *testing.T) {
+	defer healthState.Delete("http")
+	defer events.draining.Store(false)
+	old := eventsConfig.Load()
+	eventsConfig.Store(&EventsConfig{BufferSize: 256, MetricsInterval: time.Hour, Heartbeat: time.Hour})
+	defer eventsConfig.Store(old)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(eventsHandler)}
+	go srv.Serve(ln)
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/api/events?types=lifecycle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	setHealth("http", "ok", ln.Addr().String())
+
+	start := time.Now()
+	drainServers(context.Background(), "terminated", []*http.Server{srv}, nil)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("drain took %s, the event stream kept the server open", elapsed)
+	}
+	if healthy() {
+		t.Error("/ready should report unready while draining")
+	}
+
+	// The stream receives the lifecycle event, then ends
+	var draining bool
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") && strings.Contains(line, `"event":"draining"`) {
+			draining = strings.Contains(line, `"detail":"terminated"`)
+		}
+	}
+	if !draining {
+		t.Error("expected a draining lifecycle event with the reason")
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/api/events"); err == nil {
+		t.Error("the listener should be closed after draining")
+	}
+}
+
+func TestFlushTelemetry(t *testing.T) {
+	var topo Topology
+	json.Unmarshal([]byte(`{"services": [{"name": "a", "routes": [{"path": "/"}]}]}`), &topo)
+	simExporter := &closeRecorder{exported: make(map[string]int)}
+	sim, err := newSimulator(&topo, simExporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := &closeRecorder{exported: make(map[string]int)}
+	tp, err := newTracerProvider(exporter, "demo-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := tp.Tracer("test").Start(context.Background(), "last request")
+	span.End()
+
+	flushTelemetry(context.Background(), sim, tp)
+	if exporter.exported["demo-app"] != 1 || exporter.shutdowns != 1 {
+		t.Errorf("tracer exported %v and shut down %d times, want the last span flushed once", exporter.exported, exporter.shutdowns)
+	}
+	if simExporter.shutdowns != 1 {
+		t.Errorf("simulator exporter shut down %d times want 1", simExporter.shutdowns)
+	}
+	// Without a tracer or topology there is nothing to flush
+	flushTelemetry(context.Background(), nil, nil)
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// watchSignals is a no-op on platforms without SIGUSR1 and SIGHUP
+func watchSignals() {}
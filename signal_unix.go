@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchSignals dumps all goroutines to the log on SIGUSR1, which still works
+// when the HTTP servers are wedged, and reloads the configuration on SIGHUP
+func watchSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGHUP)
+	go func() {
+		for sig := range ch {
+			switch sig {
+			case syscall.SIGUSR1:
+				dumpGoroutines()
+			case syscall.SIGHUP:
+				reloadConfig()
+			}
+		}
+	}()
+}
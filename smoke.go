@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SmokeOptions controls a run of the smoke subcommand
+type SmokeOptions struct {
+	Target        string
+	Headers       http.Header
+	Timeout       time.Duration
+	Wait          time.Duration // how long to wait for /health before checking
+	Insecure      bool
+	ExpectVersion string
+	ExpectCommit  string
+	JUnitFile     string
+}
+
+// smokeCheck exercises one endpoint. JSON responses are decoded strictly into
+// Shape, and a traceId field must echo the trace ID the check sent. Run replaces
+// the plain HTTP request for streaming and WebSocket endpoints.
+type smokeCheck struct {
+	Method string
+	Path   string
+	Status int
+	Shape  func() any
+	Verify func(s *smokeRun, v any) error
+	Run    func(ctx context.Context, s *smokeRun, traceID, traceparent string) error
+}
+
+func (c smokeCheck) name() string {
+	return c.Method + " " + strings.SplitN(c.Path, "?", 2)[0]
+}
+
+// smokeChecks covers every endpoint listed on the dashboard
+var smokeChecks = []smokeCheck{
+	{Method: "GET", Path: "/health", Status: http.StatusOK,
+		Shape: func() any { return &Response{} },
+		Verify: func(_ *smokeRun, v any) error {
+			return expect("status", v.(*Response).Status, "healthy")
+		}},
+	{Method: "GET", Path: "/version", Status: http.StatusOK,
+		Shape: func() any { return &VersionInfo{} },
+		Verify: func(s *smokeRun, v any) error {
+			info := v.(*VersionInfo)
+			if s.opts.ExpectVersion != "" {
+				if err := expect("version", strings.TrimPrefix(info.Version, "v"), strings.TrimPrefix(s.opts.ExpectVersion, "v")); err != nil {
+					return err
+				}
+			}
+			if s.opts.ExpectCommit != "" && !strings.HasPrefix(info.GitCommit, s.opts.ExpectCommit) {
+				return fmt.Errorf("gitCommit is %q, expected %q", info.GitCommit, s.opts.ExpectCommit)
+			}
+			if info.Version == "" {
+				return errors.New("version is empty")
+			}
+			return nil
+		}},
+	{Method: "GET", Path: "/ready", Status: http.StatusOK,
+		Shape: func() any { return &ReadinessResponse{} },
+		Verify: func(_ *smokeRun, v any) error {
+			return expect("status", v.(*ReadinessResponse).Status, "ready")
+		}},
+	{Method: "GET", Path: "/api/hello?name=smoke", Status: http.StatusOK,
+		Shape: func() any { return &HelloResponse{} },
+		Verify: func(_ *smokeRun, v any) error {
+			if msg := v.(*HelloResponse).Message; !strings.Contains(msg, "smoke") {
+				return fmt.Errorf("message %q does not greet the name", msg)
+			}
+			return nil
+		}},
+	{Method: "GET", Path: "/api/status", Status: http.StatusOK,
+		Shape: func() any { return &StatusResponse{} },
+		Verify: func(_ *smokeRun, v any) error {
+			return expect("status", v.(*StatusResponse).Status, "running")
+		}},
+	{Method: "GET", Path: "/api/feature", Status: http.StatusOK,
+		Shape: func() any { return &FeatureResponse{} }},
+	{Method: "GET", Path: "/api/metrics", Status: http.StatusOK,
+		Shape: func() any { return &MetricsResponse{} }},
+	{Method: "GET", Path: "/api/events", Run: smokeEvents},
+	{Method: "GET", Path: "/api/echo?message=smoke", Status: http.StatusOK,
+		Shape:  func() any { return &EchoResponse{} },
+		Verify: verifyEcho("GET")},
+	{Method: "POST", Path: "/api/echo?message=smoke", Status: http.StatusOK,
+		Shape:  func() any { return &EchoResponse{} },
+		Verify: verifyEcho("POST")},
+	{Method: "GET", Path: "/api/info", Status: http.StatusOK,
+		Shape: func() any { return &InfoResponse{} }},
+	{Method: "GET", Path: "/api/time", Status: http.StatusOK,
+		Shape: func() any { return &TimeResponse{} }},
+	{Method: "GET", Path: "/api/random", Status: http.StatusOK,
+		Shape: func() any { return &RandomResponse{} }},
+	// Without a target the proxy must refuse with a problem response
+	{Method: "GET", Path: "/api/proxy", Status: http.StatusBadRequest,
+		Shape: func() any { return &Problem{} }},
+	{Method: "GET", Path: "/api/chain", Status: http.StatusOK,
+		Shape: func() any { return &ChainResponse{} },
+		Verify: func(_ *smokeRun, v any) error {
+			if hop := v.(*ChainResponse).Hop; hop != 0 {
+				return fmt.Errorf("hop is %d, expected 0", hop)
+			}
+			return nil
+		}},
+	{Method: "GET", Path: "/", Run: smokeDashboard},
+	{Method: "WS", Path: "/ws/echo", Run: smokeWebSocket("/ws/echo", "echo")},
+	{Method: "WS", Path: "/ws/broadcast?room=smoke", Run: smokeWebSocket("/ws/broadcast?room=smoke", "join")},
+}
+
+func expect(field, got, want string) error {
+	if got != want {
+		return fmt.Errorf("%s is %q, expected %q", field, got, want)
+	}
+	return nil
+}
+
+func verifyEcho(method string) func(*smokeRun, any) error {
+	return func(_ *smokeRun, v any) error {
+		echo := v.(*EchoResponse)
+		if err := expect("echo", echo.Echo, "smoke"); err != nil {
+			return err
+		}
+		return expect("method", echo.Method, method)
+	}
+}
+
+type smokeRun struct {
+	opts   SmokeOptions
+	client *http.Client
+}
+
+func newSmokeRun(opts SmokeOptions) *smokeRun {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: opts.Insecure}
+	return &smokeRun{opts: opts, client: &http.Client{Timeout: opts.Timeout, Transport: transport}}
+}
+
+// newTraceparent starts a sampled trace the target should join
+func newTraceparent() (traceID, traceparent string) {
+	var ids [24]byte
+	rand.Read(ids[:])
+	traceID = hex.EncodeToString(ids[:16])
+	return traceID, "00-" + traceID + "-" + hex.EncodeToString(ids[16:]) + "-01"
+}
+
+func (s *smokeRun) header(traceparent string) http.Header {
+	h := s.opts.Headers.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set("Traceparent", traceparent)
+	h.Set("User-Agent", "demo-app-smoke/"+Version)
+	return h
+}
+
+func (s *smokeRun) request(ctx context.Context, method, path, traceparent string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.opts.Target, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = s.header(traceparent)
+	return s.client.Do(req)
+}
+
+// check runs c and returns why it failed, or nil
+func (s *smokeRun) check(ctx context.Context, c smokeCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	traceID, traceparent := newTraceparent()
+	if c.Run != nil {
+		return c.Run(ctx, s, traceID, traceparent)
+	}
+
+	resp, err := s.request(ctx, c.Method, c.Path, traceparent)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != c.Status {
+		return fmt.Errorf("status %d, expected %d: %s", resp.StatusCode, c.Status, bytes.TrimSpace(body))
+	}
+	if c.Shape == nil {
+		return nil
+	}
+	v := c.Shape()
+	if err := decodeShape(body, v); err != nil {
+		return err
+	}
+	if err := checkTraceID(body, v, traceID); err != nil {
+		return err
+	}
+	if c.Verify != nil {
+		return c.Verify(s, v)
+	}
+	return nil
+}
+
+// decodeShape decodes body into v, rejecting unknown fields and fields of v that
+// are missing without being omitempty
+func decodeShape(body []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("response does not match %T: %w", v, err)
+	}
+	var fields map[string]json.RawMessage
+	json.Unmarshal(body, &fields)
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || strings.Contains(opts, "omitempty") {
+			continue
+		}
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("response is missing %q required by %T", name, v)
+		}
+	}
+	return nil
+}
+
+// checkTraceID requires a shape with a traceId field to return the trace the request was part of
+func checkTraceID(body []byte, v any, traceID string) error {
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name == "traceId" {
+			var got struct {
+				TraceID string `json:"traceId"`
+			}
+			json.Unmarshal(body, &got)
+			if got.TraceID != traceID {
+				return fmt.Errorf("traceId is %q, expected the propagated %s", got.TraceID, traceID)
+			}
+		}
+	}
+	return nil
+}
+
+// smokeEvents expects the event stream to open and send its retry preamble
+func smokeEvents(ctx context.Context, s *smokeRun, _, traceparent string) error {
+	resp, err := s.request(ctx, "GET", "/api/events?types=lifecycle", traceparent)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d, expected 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		return fmt.Errorf("content type %q, expected text/event-stream", ct)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no event stream data: %w", err)
+	}
+	if !strings.HasPrefix(line, "retry:") {
+		return fmt.Errorf("unexpected first line %q", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// smokeDashboard expects the rendered dashboard page
+func smokeDashboard(ctx context.Context, s *smokeRun, _, traceparent string) error {
+	resp, err := s.request(ctx, "GET", "/", traceparent)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d, expected 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		return fmt.Errorf("content type %q, expected text/html", ct)
+	}
+	if !bytes.Contains(body, []byte("data-start-time")) {
+		return errors.New("response is not the dashboard page")
+	}
+	return nil
+}
+
+// smokeWebSocket connects to path and expects a first message of type want
+// carrying the trace of the handshake
+func smokeWebSocket(path, want string) func(context.Context, *smokeRun, string, string) error {
+	return func(ctx context.Context, s *smokeRun, traceID, traceparent string) error {
+		dialer := websocket.Dialer{
+			HandshakeTimeout: s.opts.Timeout,
+			TLSClientConfig:  &tls.Config{InsecureSkipVerify: s.opts.Insecure},
+		}
+		target := "ws" + strings.TrimPrefix(strings.TrimSuffix(s.opts.Target, "/"), "http") + path
+		conn, resp, err := dialer.DialContext(ctx, target, s.header(traceparent))
+		if err != nil {
+			if resp != nil {
+				return fmt.Errorf("handshake failed with status %d: %w", resp.StatusCode, err)
+			}
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetReadDeadline(deadline)
+		}
+		if want == "echo" {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("smoke")); err != nil {
+				return err
+			}
+		}
+
+		var msg WSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("no %s message: %w", want, err)
+		}
+		if err := expect("type", msg.Type, want); err != nil {
+			return err
+		}
+		if want == "echo" && msg.Data != "smoke" {
+			return fmt.Errorf("data is %q, expected %q", msg.Data, "smoke")
+		}
+		if msg.TraceID != traceID {
+			return fmt.Errorf("traceId is %q, expected the propagated %s", msg.TraceID, traceID)
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		return nil
+	}
+}
+
+// waitHealthy polls /health until it answers 200 or the wait is over
+func (s *smokeRun) waitHealthy(ctx context.Context) error {
+	deadline := time.Now().Add(s.opts.Wait)
+	for {
+		_, traceparent := newTraceparent()
+		resp, err := s.request(ctx, "GET", "/health", traceparent)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s is not healthy after %s: %w", s.opts.Target, s.opts.Wait, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+type junitTestSuite struct {
+	XMLName    xml.Name        `xml:"testsuite"`
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// smoke runs every check against the target, logging each result to out
+func smoke(ctx context.Context, s *smokeRun, checks []smokeCheck, out io.Writer) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      "smoke",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Properties: []junitProperty{
+			{Name: "target", Value: s.opts.Target},
+			{Name: "expectedVersion", Value: s.opts.ExpectVersion},
+			{Name: "expectedCommit", Value: s.opts.ExpectCommit},
+		},
+	}
+	start := time.Now()
+	for _, c := range checks {
+		began := time.Now()
+		err := s.check(ctx, c)
+		tc := junitTestCase{
+			Name:      c.name(),
+			ClassName: "smoke",
+			Time:      fmt.Sprintf("%.3f", time.Since(began).Seconds()),
+		}
+		if err != nil {
+			tc.Failure = &junitFailure{Message: err.Error(), Type: "AssertionError", Text: c.Method + " " + c.Path + ": " + err.Error()}
+			suite.Failures++
+			fmt.Fprintf(out, "FAIL %-24s %v\n", c.name(), err)
+		} else {
+			fmt.Fprintf(out, "ok   %-24s %s\n", c.name(), time.Since(began).Round(time.Millisecond))
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Tests = len(checks)
+	suite.Time = fmt.Sprintf("%.3f", time.Since(start).Seconds())
+	return suite
+}
+
+// parseSmokeFlags reads the smoke subcommand flags, defaulting the target from cfg
+func parseSmokeFlags(args []string, cfg *Config, output io.Writer) (SmokeOptions, error) {
+	opts := SmokeOptions{Headers: http.Header{}}
+	fs := flag.NewFlagSet("smoke", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.Target, "target", "http://localhost:"+cfg.Port, "base URL of the deployment")
+	fs.Var(headerFlag(opts.Headers), "H", "extra request header \"Name: value\", repeatable")
+	fs.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "per-check timeout")
+	fs.DurationVar(&opts.Wait, "wait", 30*time.Second, "how long to wait for /health before the checks")
+	fs.BoolVar(&opts.Insecure, "insecure", false, "skip TLS certificate verification")
+	fs.StringVar(&opts.ExpectVersion, "expect-version", "", "fail unless /version reports this version")
+	fs.StringVar(&opts.ExpectCommit, "expect-commit", "", "fail unless /version reports this commit (prefix)")
+	fs.StringVar(&opts.JUnitFile, "junit", "smoke-junit.xml", "JUnit XML report path, empty to skip")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	switch {
+	case fs.NArg() > 0:
+		return opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	case !strings.HasPrefix(opts.Target, "http://") && !strings.HasPrefix(opts.Target, "https://"):
+		return opts, fmt.Errorf("-target must be an http or https URL, got %q", opts.Target)
+	case opts.Timeout <= 0, opts.Wait < 0:
+		return opts, errors.New("-timeout must be positive and -wait not negative")
+	}
+	return opts, nil
+}
+
+// runSmoke implements `demo-app smoke`: exit status 1 when any check fails
+func runSmoke(args []string) int {
+	cfg, err := readConfig()
+	if err != nil {
+		log.Printf("[ERROR] Invalid configuration: %v", err)
+		return 1
+	}
+	opts, err := parseSmokeFlags(args, cfg, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "smoke: %v\n", err)
+		return 2
+	}
+
+	ctx := context.Background()
+	s := newSmokeRun(opts)
+	var suite junitTestSuite
+	if err := s.waitHealthy(ctx); err != nil {
+		log.Printf("[ERROR] %v", err)
+		suite = junitTestSuite{Name: "smoke", Tests: 1, Failures: 1, Time: "0", Timestamp: time.Now().UTC().Format(time.RFC3339),
+			TestCases: []junitTestCase{{Name: "GET /health", ClassName: "smoke", Time: "0",
+				Failure: &junitFailure{Message: err.Error(), Type: "Unavailable", Text: err.Error()}}}}
+	} else {
+		suite = smoke(ctx, s, smokeChecks, os.Stdout)
+	}
+	fmt.Printf("%d checks, %d failed\n", suite.Tests, suite.Failures)
+
+	if opts.JUnitFile != "" {
+		data, _ := xml.MarshalIndent(suite, "", "  ")
+		if err := os.WriteFile(opts.JUnitFile, append([]byte(xml.Header), append(data, '\n')...), 0o644); err != nil {
+			log.Printf("[ERROR] Failed to write JUnit report: %v", err)
+			return 1
+		}
+	}
+	if suite.Failures > 0 {
+		return 1
+	}
+	return 0
+}
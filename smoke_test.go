@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSmokeCoversEndpoints(t *testing.T) {
+	checked := make(map[string]bool)
+	for _, c := range smokeChecks {
+		checked[strings.SplitN(c.Path, "?", 2)[0]] = true
+	}
+	for _, e := range dashboardEndpoints {
+		if !checked[e.Path] {
+			t.Errorf("%s %s has no smoke check", e.Method, e.Path)
+		}
+	}
+}
+
+func TestDecodeShape(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"valid", `{"message": "hi", "locale": "en-US", "timestamp": "now"}`, ""},
+		{"optional trace id", `{"message": "hi", "locale": "en-US", "timestamp": "now", "traceId": "abc"}`, ""},
+		{"unknown field", `{"message": "hi", "locale": "en-US", "timestamp": "now", "extra": 1}`, "unknown field"},
+		{"optional locale", `{"message": "hi", "timestamp": "now"}`, ""},
+		{"missing field", `{"message": "hi", "locale": "en-US"}`, `missing "timestamp"`},
+		{"wrong type", `{"message": 1, "locale": "en-US", "timestamp": "now"}`, "does not match"},
+		{"not json", `<html>`, "does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, decodeShape([]byte(tt.body), &HelloResponse{}), tt.err)
+		})
+	}
+}
+
+func TestSmoke(t *testing.T) {
+	oldTP, oldProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	applyConfig(loadConfig())
+	t.Cleanup(func() {
+		otel.SetTracerProvider(oldTP)
+		otel.SetTextMapPropagator(oldProp)
+	})
+	srv := httptest.NewServer(otelhttp.NewHandler(localize(newAppMux()), "test"))
+	defer srv.Close()
+
+	opts := SmokeOptions{Target: srv.URL, Timeout: 5 * time.Second, ExpectVersion: "v" + Version, ExpectCommit: GitCommit}
+	s := newSmokeRun(opts)
+	if err := s.waitHealthy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	suite := smoke(context.Background(), s, smokeChecks, io.Discard)
+	if suite.Tests != len(smokeChecks) || suite.Failures != 0 {
+		for _, tc := range suite.TestCases {
+			if tc.Failure != nil {
+				t.Errorf("%s: %s", tc.Name, tc.Failure.Message)
+			}
+		}
+		t.Fatalf("%d of %d checks failed", suite.Failures, suite.Tests)
+	}
+
+	// A deployment of the wrong version fails only the /version check
+	opts.ExpectVersion = "9.9.9"
+	suite = smoke(context.Background(), newSmokeRun(opts), smokeChecks, io.Discard)
+	if suite.Failures != 1 || suite.TestCases[1].Failure == nil {
+		t.Fatalf("expected only GET /version to fail: %+v", suite)
+	}
+	data, err := xml.Marshal(suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<testcase name="GET /version" classname="smoke"`) ||
+		!strings.Contains(string(data), `<failure message="version is &#34;`+Version) {
+		t.Errorf("unexpected JUnit report: %s", data)
+	}
+
+	// Nothing listening
+	srv.Close()
+	opts.Wait = 0
+	if err := newSmokeRun(opts).waitHealthy(context.Background()); err == nil {
+		t.Error("expected an unreachable target to fail")
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// stressKind describes one kind of resource a stress session consumes
+type stressKind struct {
+	param string // query parameter carrying the amount
+	unit  string
+	limit func(StressConfig) int
+	run   func(ctx context.Context, s *StressSession) error
+}
+
+var stressKinds = map[string]stressKind{
+	"cpu":        {"cores", "cores", func(c StressConfig) int { return c.MaxCores }, burnCPU},
+	"memory":     {"mb", "MB", func(c StressConfig) int { return c.MaxMemoryMB }, holdMemory},
+	"goroutines": {"count", "goroutines", func(c StressConfig) int { return c.MaxGoroutines }, spawnGoroutines},
+	"disk":       {"mb", "MB", func(c StressConfig) int { return c.MaxDiskMB }, churnDisk},
+}
+
+// StressSession is a running resource stress started through the admin API
+type StressSession struct {
+	ID           int    `json:"id"`
+	Kind         string `json:"kind"`
+	Amount       int    `json:"amount"`
+	Unit         string `json:"unit"`
+	Duration     string `json:"duration"`
+	StartedAt    string `json:"startedAt"`
+	ExpiresAt    string `json:"expiresAt"`
+	TraceID      string `json:"traceId,omitempty"`
+	BytesWritten int64  `json:"bytesWritten,omitempty"`
+
+	cancel  context.CancelFunc
+	written int64
+}
+
+// snapshot copies the session so it can be encoded while it is still running
+func (s *StressSession) snapshot() StressSession {
+	return StressSession{
+		ID:           s.ID,
+		Kind:         s.Kind,
+		Amount:       s.Amount,
+		Unit:         s.Unit,
+		Duration:     s.Duration,
+		StartedAt:    s.StartedAt,
+		ExpiresAt:    s.ExpiresAt,
+		TraceID:      s.TraceID,
+		BytesWritten: atomic.LoadInt64(&s.written),
+	}
+}
+
+type stressManager struct {
+	mu       sync.Mutex
+	limits   StressConfig
+	nextID   int
+	sessions map[int]*StressSession
+}
+
+var stress = &stressManager{
+	limits:   loadConfig().Stress,
+	nextID:   1,
+	sessions: make(map[int]*StressSession),
+}
+
+func (m *stressManager) setLimits(limits StressConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = limits
+}
+
+// Start validates the request against the safety limits and launches the session
+func (m *stressManager) Start(ctx context.Context, kind string, amount int, duration time.Duration) (StressSession, error) {
+	k, ok := stressKinds[kind]
+	if !ok {
+		return StressSession{}, fmt.Errorf("unknown stress kind %q", kind)
+	}
+	if amount <= 0 {
+		return StressSession{}, fmt.Errorf("%s must be positive", k.param)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if duration <= 0 || duration > m.limits.MaxDuration {
+		return StressSession{}, fmt.Errorf("duration must be between 0 and %s", m.limits.MaxDuration)
+	}
+	inUse := 0
+	for _, s := range m.sessions {
+		if s.Kind == kind {
+			inUse += s.Amount
+		}
+	}
+	if max := k.limit(m.limits); inUse+amount > max {
+		return StressSession{}, fmt.Errorf("%s stress limited to %d %s in total, %d already in use", kind, max, k.unit, inUse)
+	}
+
+	// The session outlives the request, so it gets its own trace linked to the caller
+	sessionCtx, cancel := context.WithTimeout(context.Background(), duration)
+	var span trace.Span
+	sessionCtx, span = otel.Tracer("demo-app").Start(sessionCtx, "stress."+kind,
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(
+			attribute.String("stress.kind", kind),
+			attribute.Int("stress.amount", amount),
+			attribute.String("stress.unit", k.unit),
+			attribute.String("stress.duration", duration.String()),
+		),
+	)
+
+	now := time.Now().UTC()
+	s := &StressSession{
+		ID:        m.nextID,
+		Kind:      kind,
+		Amount:    amount,
+		Unit:      k.unit,
+		Duration:  duration.String(),
+		StartedAt: now.Format(time.RFC3339),
+		ExpiresAt: now.Add(duration).Format(time.RFC3339),
+		TraceID:   getTraceID(sessionCtx),
+		cancel:    cancel,
+	}
+	m.nextID++
+	m.sessions[s.ID] = s
+
+	go func() {
+		defer span.End()
+		defer cancel()
+		err := k.run(sessionCtx, s)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			log.Printf("[ERROR] Stress session %d (%s) failed: %v, traceId: %s", s.ID, kind, err, s.TraceID)
+		} else if sessionCtx.Err() == context.Canceled {
+			span.AddEvent("stress.cancelled")
+		}
+		m.mu.Lock()
+		delete(m.sessions, s.ID)
+		m.mu.Unlock()
+		log.Printf("[INFO] Stress session %d (%s) finished, traceId: %s", s.ID, kind, s.TraceID)
+	}()
+
+	log.Printf("[INFO] Stress session %d started, kind: %s, amount: %d %s, duration: %s, traceId: %s",
+		s.ID, kind, amount, k.unit, duration, s.TraceID)
+	return s.snapshot(), nil
+}
+
+// Cancel stops one session, or all sessions when id is 0. It returns how many were cancelled.
+func (m *stressManager) Cancel(id int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, s := range m.sessions {
+		if id == 0 || s.ID == id {
+			s.cancel()
+			n++
+		}
+	}
+	return n
+}
+
+// Active returns a snapshot of the running sessions
+func (m *stressManager) Active() []StressSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]StressSession, 0, len(m.sessions))
+	for id := 1; id < m.nextID; id++ {
+		if s, ok := m.sessions[id]; ok {
+			list = append(list, s.snapshot())
+		}
+	}
+	return list
+}
+
+func registerStressHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/stress", stressListHandler)
+	mux.HandleFunc("/admin/stress/", stressStartHandler)
+}
+
+// stressListHandler lists sessions (GET) or cancels ?id= or all of them (DELETE)
+func stressListHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, stress.Active())
+	case http.MethodDelete:
+		id := 0
+		if v := r.URL.Query().Get("id"); v != "" {
+			var err error
+			if id, err = strconv.Atoi(v); err != nil || id <= 0 {
+				writeAdminError(w, http.StatusBadRequest, "invalid id: "+v)
+				return
+			}
+		}
+		n := stress.Cancel(id)
+		if id != 0 && n == 0 {
+			writeAdminError(w, http.StatusNotFound, fmt.Sprintf("stress session %d not found", id))
+			return
+		}
+		log.Printf("[INFO] Cancelled %d stress session(s)", n)
+		writeJSON(w, http.StatusOK, map[string]int{"cancelled": n})
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// stressStartHandler starts a session, e.g. POST /admin/stress/cpu?cores=2&duration=30s
+func stressStartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	kind := strings.TrimPrefix(r.URL.Path, "/admin/stress/")
+	k, ok := stressKinds[kind]
+	if !ok {
+		writeAdminError(w, http.StatusNotFound, fmt.Sprintf("unknown stress kind %q", kind))
+		return
+	}
+
+	amount, err := strconv.Atoi(r.URL.Query().Get(k.param))
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, k.param+" must be an integer")
+		return
+	}
+	duration := 30 * time.Second
+	if v := r.URL.Query().Get("duration"); v != "" {
+		if duration, err = time.ParseDuration(v); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid duration: "+v)
+			return
+		}
+	}
+
+	s, err := stress.Start(r.Context(), kind, amount, duration)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s)
+}
+
+func burnCPU(ctx context.Context, s *StressSession) error {
+	var wg sync.WaitGroup
+	for i := 0; i < s.Amount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			x := 0
+			for {
+				for j := 0; j < 1000000; j++ {
+					x += j * j
+				}
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func holdMemory(ctx context.Context, s *StressSession) error {
+	const chunk = 1 << 20
+	held := make([][]byte, s.Amount)
+	for i := range held {
+		held[i] = make([]byte, chunk)
+		// Touch every page so the memory is actually resident
+		for j := 0; j < chunk; j += 4096 {
+			held[i][j] = 1
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	<-ctx.Done()
+	runtime.KeepAlive(held)
+	held = nil
+	debug.FreeOSMemory()
+	return nil
+}
+
+func spawnGoroutines(ctx context.Context, s *StressSession) error {
+	var wg sync.WaitGroup
+	for i := 0; i < s.Amount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-ctx.Done()
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// churnDisk repeatedly writes, syncs and reads back a file of the requested size
+func churnDisk(ctx context.Context, s *StressSession) error {
+	dir, err := os.MkdirTemp("", "demo-app-stress-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	buf := make([]byte, 1<<20)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	path := filepath.Join(dir, "stress.dat")
+	for ctx.Err() == nil {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < s.Amount && ctx.Err() == nil; i++ {
+			if _, err := f.Write(buf); err != nil {
+				f.Close()
+				return err
+			}
+			atomic.AddInt64(&s.written, int64(len(buf)))
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		f.Close()
+		if _, err := os.ReadFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
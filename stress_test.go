@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStressStartHandler(t *testing.T) {
+	defer stress.Cancel(0)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"goroutines", "/admin/stress/goroutines?count=10&duration=5s", http.StatusAccepted},
+		{"over limit", "/admin/stress/goroutines?count=100000000&duration=5s", http.StatusBadRequest},
+		{"too long", "/admin/stress/cpu?cores=1&duration=24h", http.StatusBadRequest},
+		{"missing amount", "/admin/stress/memory?duration=5s", http.StatusBadRequest},
+		{"unknown kind", "/admin/stress/network?mb=1", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(stressStartHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v (%s)", status, tt.expected, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestStressSessionVisibleAndCancellable(t *testing.T) {
+	s, err := stress.Start(httptest.NewRequest("POST", "/", nil).Context(), "memory", 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	metricsHandler(rr, httptest.NewRequest("GET", "/api/metrics", nil))
+	var metrics MetricsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	found := false
+	for _, m := range metrics.Stress {
+		found = found || m.ID == s.ID
+	}
+	if !found {
+		t.Errorf("stress session %d not reported in metrics: %+v", s.ID, metrics.Stress)
+	}
+
+	rr = httptest.NewRecorder()
+	stressListHandler(rr, httptest.NewRequest("DELETE", "/admin/stress?id="+strconv.Itoa(s.ID), nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(stress.Active()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(stress.Active()); n != 0 {
+		t.Errorf("expected no active sessions after cancel, got %d", n)
+	}
+}
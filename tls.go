@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// certReloader serves the certificate, key and client CA bundle from disk and
+// picks up rotated files without restarting the listener
+type certReloader struct {
+	cfg TLSConfig
+
+	mu      sync.Mutex
+	modTime time.Time
+	cert    atomic.Pointer[tls.Certificate]
+	clients atomic.Pointer[x509.CertPool]
+}
+
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	cr := &certReloader{cfg: cfg}
+	if cfg.SelfSigned {
+		cert, err := selfSignedCertificate(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		cr.cert.Store(cert)
+		log.Printf("[WARN] Serving a self-signed certificate, sha256 fingerprint: %s", fingerprint(cert.Leaf))
+	} else if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("TLS requires TLS_CERT_FILE and TLS_KEY_FILE, or TLS_SELF_SIGNED=true")
+	}
+	if _, err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// reload re-reads the files if any of them changed since the last load.
+// On error the previous certificate stays in use.
+func (cr *certReloader) reload() (bool, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	var files []string
+	if !cr.cfg.SelfSigned {
+		files = append(files, cr.cfg.CertFile, cr.cfg.KeyFile)
+	}
+	if cr.cfg.ClientCAFile != "" {
+		files = append(files, cr.cfg.ClientCAFile)
+	}
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	if !latest.After(cr.modTime) {
+		return false, nil
+	}
+
+	if !cr.cfg.SelfSigned {
+		cert, err := tls.LoadX509KeyPair(cr.cfg.CertFile, cr.cfg.KeyFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to load TLS key pair: %w", err)
+		}
+		cr.cert.Store(&cert)
+	}
+	if cr.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cr.cfg.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("no certificates found in %s", cr.cfg.ClientCAFile)
+		}
+		cr.clients.Store(pool)
+	}
+	cr.modTime = latest
+	return true, nil
+}
+
+// watch polls the files for rotation until the process exits
+func (cr *certReloader) watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	for range time.Tick(interval) {
+		if changed, err := cr.reload(); err != nil {
+			log.Printf("[ERROR] TLS certificate reload failed, keeping the previous one: %v", err)
+			publishLifecycle("tls.reload_failed", err.Error())
+			setHealth("tls", "fail", err.Error())
+		} else if changed {
+			log.Printf("[INFO] TLS certificate reloaded")
+			publishLifecycle("tls.reloaded", cr.cfg.CertFile)
+			setHealth("tls", "ok", cr.expiry())
+		}
+	}
+}
+
+// expiry describes when the served certificate expires
+func (cr *certReloader) expiry() string {
+	cert := cr.cert.Load()
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return ""
+		}
+	}
+	return "expires " + leaf.NotAfter.UTC().Format(time.RFC3339)
+}
+
+// clientAuthType maps the TLS_CLIENT_AUTH setting to the crypto/tls policy
+func clientAuthType(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "verify-if-given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("unknown TLS client auth mode %q", mode)
+}
+
+// tlsConfig builds a server config whose certificate and client CAs are looked up per handshake
+func (cr *certReloader) tlsConfig() (*tls.Config, error) {
+	clientAuth, err := clientAuthType(cr.cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && cr.cfg.ClientCAFile == "" {
+		return nil, errors.New("verifying client certificates requires TLS_CLIENT_CA_FILE")
+	}
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.GetConfigForClient = nil
+		c.Certificates = []tls.Certificate{*cr.cert.Load()}
+		c.ClientAuth = clientAuth
+		c.ClientCAs = cr.clients.Load()
+		return c, nil
+	}
+	return base, nil
+}
+
+// selfSignedCertificate creates an ECDSA certificate for localhost and the host name
+func selfSignedCertificate(now time.Time) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	dnsNames := []string{"localhost"}
+	if host, err := os.Hostname(); err == nil && host != "localhost" {
+		dnsNames = append(dnsNames, host)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "demo-app self-signed", Organization: []string{"demo-app"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              dnsNames,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// TLSInfo describes the connection and the client certificate, if one was presented
+type TLSInfo struct {
+	Version     string          `json:"version"`
+	CipherSuite string          `json:"cipherSuite"`
+	ServerName  string          `json:"serverName,omitempty"`
+	ClientCert  *ClientCertInfo `json:"clientCert,omitempty"`
+}
+
+type ClientCertInfo struct {
+	Subject      string   `json:"subject"`
+	Issuer       string   `json:"issuer"`
+	SerialNumber string   `json:"serialNumber"`
+	NotBefore    string   `json:"notBefore"`
+	NotAfter     string   `json:"notAfter"`
+	DNSNames     []string `json:"dnsNames,omitempty"`
+	URIs         []string `json:"uris,omitempty"` // e.g. SPIFFE IDs
+	SHA256       string   `json:"sha256"`
+	Verified     bool     `json:"verified"`
+}
+
+func tlsInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		cc := &ClientCertInfo{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.Text(16),
+			NotBefore:    cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:     cert.NotAfter.UTC().Format(time.RFC3339),
+			DNSNames:     cert.DNSNames,
+			SHA256:       fingerprint(cert),
+			Verified:     len(state.VerifiedChains) > 0,
+		}
+		for _, u := range cert.URIs {
+			cc.URIs = append(cc.URIs, u.String())
+		}
+		info.ClientCert = cc
+	}
+	return info
+}
+
+// annotateTLS records the negotiated TLS parameters and client certificate on the server span
+func annotateTLS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := tlsInfo(r.TLS); info != nil {
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(
+				attribute.String("tls.protocol.version", info.Version),
+				attribute.String("tls.cipher", info.CipherSuite),
+				attribute.Bool("tls.client.certificate_presented", info.ClientCert != nil),
+			)
+			if cc := info.ClientCert; cc != nil {
+				span.SetAttributes(
+					attribute.String("tls.client.subject", cc.Subject),
+					attribute.String("tls.client.issuer", cc.Issuer),
+					attribute.String("tls.client.serial_number", cc.SerialNumber),
+					attribute.String("tls.client.not_after", cc.NotAfter),
+					attribute.String("tls.client.hash.sha256", cc.SHA256),
+					attribute.StringSlice("tls.client.san.uri", cc.URIs),
+					attribute.Bool("tls.client.verified", cc.Verified),
+				)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
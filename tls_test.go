@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issueCert signs a certificate for cn with parent, or self-signs when parent is nil
+func issueCert(t *testing.T, cn string, parent *tls.Certificate, isCA bool) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		URIs:                  []*url.URL{{Scheme: "spiffe", Host: "example.org", Path: "/ns/default/sa/" + cn}},
+	}
+	signer, signerKey := tmpl, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func writePEM(t *testing.T, dir string, name string, cert *tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writeFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})))
+	writeFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})))
+	return certFile, keyFile
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := issueCert(t, "test-ca", nil, true)
+	caFile, _ := writePEM(t, dir, "ca", ca)
+	certFile, keyFile := writePEM(t, dir, "server", issueCert(t, "server", ca, false))
+
+	certs, err := newCertReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: "require"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(annotateTLS(http.HandlerFunc(echoHandler)))
+	if srv.TLS, err = certs.tlsConfig(); err != nil {
+		t.Fatal(err)
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	if _, err := client().Get(srv.URL + "/api/echo"); err == nil {
+		t.Error("request without a client certificate succeeded")
+	}
+
+	resp, err := client(*issueCert(t, "checkout", ca, false)).Get(srv.URL + "/api/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var echo EchoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&echo); err != nil {
+		t.Fatal(err)
+	}
+	cc := echo.TLS.ClientCert
+	if cc == nil || cc.Subject != "CN=checkout" || !cc.Verified {
+		t.Fatalf("unexpected client certificate info: %+v", echo.TLS)
+	}
+	if len(cc.URIs) != 1 || cc.URIs[0] != "spiffe://example.org/ns/default/sa/checkout" {
+		t.Errorf("unexpected URIs: %v", cc.URIs)
+	}
+}
+
+func TestCertReloaderRotation(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePEM(t, dir, "server", issueCert(t, "first", nil, false))
+	certs, err := newCertReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := certs.reload(); changed || err != nil {
+		t.Errorf("reload without changes: changed %v, err %v", changed, err)
+	}
+
+	writePEM(t, dir, "server", issueCert(t, "second", nil, false))
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if changed, err := certs.reload(); !changed || err != nil {
+		t.Fatalf("reload after rotation: changed %v, err %v", changed, err)
+	}
+	leaf, _ := x509.ParseCertificate(certs.cert.Load().Certificate[0])
+	if leaf.Subject.CommonName != "second" {
+		t.Errorf("rotated certificate not served, got CN %q", leaf.Subject.CommonName)
+	}
+
+	// A broken file keeps the previous certificate
+	writeFile(t, keyFile, "garbage")
+	later := future.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	if _, err := certs.reload(); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+	if certs.cert.Load() == nil {
+		t.Error("certificate was dropped after a failed reload")
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	certs, err := newCertReloader(TLSConfig{SelfSigned: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := certs.cert.Load().Leaf
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if _, err := clientAuthType("bogus"); err == nil {
+		t.Error("expected an error for an unknown client auth mode")
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Topology describes the virtual services run by the simulator
+type Topology struct {
+	Services []VirtualService `json:"services"`
+}
+
+// VirtualService is served on its own Port, or on the shared TOPOLOGY_PORT listener
+// where it is selected by the Host header (name or name.localhost)
+type VirtualService struct {
+	Name   string         `json:"name"`
+	Port   string         `json:"port,omitempty"`
+	Routes []VirtualRoute `json:"routes"`
+}
+
+type VirtualRoute struct {
+	Path        string         `json:"path"`
+	Latency     LatencyProfile `json:"latency"`
+	ErrorRate   float64        `json:"errorRate,omitempty"`
+	ErrorStatus int            `json:"errorStatus,omitempty"` // defaults to 500
+	Spans       []VirtualSpan  `json:"spans,omitempty"`
+	Calls       []VirtualCall  `json:"calls,omitempty"`
+}
+
+// VirtualSpan is local work recorded as a child span, like the database and cache
+// lookups simulated by echoHandler and randomHandler
+type VirtualSpan struct {
+	Name       string            `json:"name"`
+	Latency    LatencyProfile    `json:"latency"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// VirtualCall calls another virtual service's route FanOut times (default once)
+type VirtualCall struct {
+	Service  string `json:"service"`
+	Path     string `json:"path"`
+	FanOut   int    `json:"fanOut,omitempty"`
+	Parallel bool   `json:"parallel,omitempty"`
+	Optional bool   `json:"optional,omitempty"` // a failure does not fail the caller
+}
+
+// LatencyProfile is Base plus a uniform Jitter; SlowRate of requests take Slow instead
+type LatencyProfile struct {
+	Base     simDuration `json:"base"`
+	Jitter   simDuration `json:"jitter,omitempty"`
+	SlowRate float64     `json:"slowRate,omitempty"`
+	Slow     simDuration `json:"slow,omitempty"`
+}
+
+// simDuration reads durations such as "20ms" from the topology file
+type simDuration time.Duration
+
+func (d *simDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"20ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = simDuration(v)
+	return nil
+}
+
+func (p LatencyProfile) sample() time.Duration {
+	if p.SlowRate > 0 && rand.Float64() < p.SlowRate {
+		return time.Duration(p.Slow)
+	}
+	d := time.Duration(p.Base)
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	return d
+}
+
+// SimResponse is returned by every virtual route
+type SimResponse struct {
+	Service    string    `json:"service"`
+	Route      string    `json:"route"`
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"durationMs"`
+	Calls      []SimCall `json:"calls,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	TraceID    string    `json:"traceId,omitempty"`
+	Timestamp  string    `json:"timestamp"`
+}
+
+type SimCall struct {
+	Service    string  `json:"service"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func loadTopology(path string) (*Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology file: %w", err)
+	}
+	var topo Topology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		return nil, fmt.Errorf("failed to parse topology file %s: %w", path, err)
+	}
+	if err := topo.validate(); err != nil {
+		return nil, fmt.Errorf("invalid topology %s: %w", path, err)
+	}
+	return &topo, nil
+}
+
+// validate checks references between services and rejects call cycles
+func (t *Topology) validate() error {
+	if len(t.Services) == 0 {
+		return errors.New("no services defined")
+	}
+	names := make(map[string]bool)
+	routes := make(map[string]*VirtualRoute) // keyed by "service path"
+	for i, svc := range t.Services {
+		if svc.Name == "" {
+			return fmt.Errorf("service %d has no name", i)
+		}
+		if names[svc.Name] {
+			return fmt.Errorf("duplicate service %q", svc.Name)
+		}
+		names[svc.Name] = true
+		for j := range svc.Routes {
+			r := &t.Services[i].Routes[j]
+			if !strings.HasPrefix(r.Path, "/") {
+				return fmt.Errorf("%s: route path %q must start with /", svc.Name, r.Path)
+			}
+			if r.ErrorRate < 0 || r.ErrorRate > 1 {
+				return fmt.Errorf("%s %s: errorRate must be between 0 and 1", svc.Name, r.Path)
+			}
+			key := svc.Name + " " + r.Path
+			if _, dup := routes[key]; dup {
+				return fmt.Errorf("%s: duplicate route %s", svc.Name, r.Path)
+			}
+			routes[key] = r
+		}
+	}
+	for key, r := range routes {
+		for _, c := range r.Calls {
+			if _, ok := routes[c.Service+" "+c.Path]; !ok {
+				return fmt.Errorf("%s calls unknown route %s %s", key, c.Service, c.Path)
+			}
+			if c.FanOut < 0 {
+				return fmt.Errorf("%s: fanOut must not be negative", key)
+			}
+		}
+	}
+
+	// Depth-first search over routes; reaching a route still on the stack is a cycle
+	const visiting, done = 1, 2
+	state := make(map[string]int)
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch state[key] {
+		case visiting:
+			return fmt.Errorf("call cycle: %s -> %s", strings.Join(path, " -> "), key)
+		case done:
+			return nil
+		}
+		state[key] = visiting
+		for _, c := range routes[key].Calls {
+			if err := visit(c.Service+" "+c.Path, append(path, key)); err != nil {
+				return err
+			}
+		}
+		state[key] = done
+		return nil
+	}
+	for key := range routes {
+		if err := visit(key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// simService is one virtual service with its own tracer provider, so its spans
+// carry its own service.name
+type simService struct {
+	def     VirtualService
+	tp      *sdktrace.TracerProvider
+	tracer  trace.Tracer
+	client  *http.Client
+	handler http.Handler
+	baseURL string
+	host    string // Host header for services on the shared listener
+}
+
+type simulator struct {
+	services map[string]*simService
+	servers  []*http.Server
+	exporter sdktrace.SpanExporter
+}
+
+// sharedExporter lets every service's provider batch into one exporter: shutting
+// a provider down flushes its spans but leaves the exporter open for the others
+type sharedExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (sharedExporter) Shutdown(context.Context) error { return nil }
+
+var simPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+func newSimulator(topo *Topology, exporter sdktrace.SpanExporter) (*simulator, error) {
+	sim := &simulator{services: make(map[string]*simService), exporter: exporter}
+	for _, def := range topo.Services {
+		tp, err := newTracerProvider(sharedExporter{exporter}, def.Name, attribute.Bool("demo.simulated", true))
+		if err != nil {
+			return nil, err
+		}
+		svc := &simService{
+			def:    def,
+			tp:     tp,
+			tracer: tp.Tracer("demo-app/topology"),
+			client: &http.Client{
+				Transport: otelhttp.NewTransport(http.DefaultTransport, otelhttp.WithTracerProvider(tp), otelhttp.WithPropagators(simPropagator)),
+				Timeout:   30 * time.Second,
+			},
+		}
+		mux := http.NewServeMux()
+		for _, route := range def.Routes {
+			mux.HandleFunc(route.Path, sim.serveRoute(svc, route))
+		}
+		svc.handler = otelhttp.NewHandler(mux, def.Name,
+			otelhttp.WithTracerProvider(tp),
+			otelhttp.WithPropagators(simPropagator),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method + " " + r.URL.Path }),
+		)
+		sim.services[def.Name] = svc
+	}
+	return sim, nil
+}
+
+// listen binds every service with its own port plus the shared virtual-host listener
+func (sim *simulator) listen(sharedPort string) error {
+	var shared net.Listener
+	for _, svc := range sim.services {
+		port := svc.def.Port
+		if port == "" {
+			if shared == nil {
+				ln, err := net.Listen("tcp", ":"+sharedPort)
+				if err != nil {
+					return fmt.Errorf("failed to listen on :%s: %w", sharedPort, err)
+				}
+				shared = ln
+				sim.serve(ln, http.HandlerFunc(sim.routeByHost))
+			}
+			svc.baseURL = "http://" + loopbackAddr(shared)
+			svc.host = svc.def.Name
+			continue
+		}
+		ln, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			return fmt.Errorf("%s: failed to listen on :%s: %w", svc.def.Name, port, err)
+		}
+		svc.baseURL = "http://" + loopbackAddr(ln)
+		sim.serve(ln, svc.handler)
+	}
+	return nil
+}
+
+func loopbackAddr(ln net.Listener) string {
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+func (sim *simulator) serve(ln net.Listener, handler http.Handler) {
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	sim.servers = append(sim.servers, srv)
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[ERROR] Topology listener stopped: %v", err)
+		}
+	}()
+}
+
+// routeByHost dispatches shared-listener requests to the service named by the Host header
+func (sim *simulator) routeByHost(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".localhost")
+	svc, ok := sim.services[host]
+	if !ok || svc.def.Port != "" {
+		writeProblem(w, r, http.StatusNotFound, fmt.Sprintf("no virtual service %q on this port", host))
+		return
+	}
+	svc.handler.ServeHTTP(w, r)
+}
+
+func (sim *simulator) close() {
+	for _, srv := range sim.servers {
+		srv.Close()
+	}
+	for _, svc := range sim.services {
+		svc.tp.Shutdown(context.Background())
+	}
+	sim.exporter.Shutdown(context.Background())
+}
+
+func (sim *simulator) serveRoute(svc *simService, route VirtualRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		start := time.Now()
+		span := trace.SpanFromContext(ctx)
+
+		for _, work := range route.Spans {
+			_, child := svc.tracer.Start(ctx, work.Name)
+			for k, v := range work.Attributes {
+				child.SetAttributes(attribute.String(k, v))
+			}
+			time.Sleep(work.Latency.sample())
+			child.End()
+		}
+
+		calls, callErr := sim.callAll(ctx, svc, route.Calls)
+		time.Sleep(route.Latency.sample())
+
+		response := SimResponse{
+			Service: svc.def.Name,
+			Route:   route.Path,
+			Status:  http.StatusOK,
+			Calls:   calls,
+			TraceID: getTraceID(ctx),
+		}
+		switch {
+		case callErr != nil:
+			response.Status, response.Error = http.StatusBadGateway, callErr.Error()
+		case route.ErrorRate > 0 && rand.Float64() < route.ErrorRate:
+			response.Status, response.Error = http.StatusInternalServerError, "simulated error"
+			if route.ErrorStatus != 0 {
+				response.Status = route.ErrorStatus
+			}
+		}
+		if response.Error != "" {
+			span.SetStatus(codes.Error, response.Error)
+			span.RecordError(errors.New(response.Error))
+		}
+		response.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+		response.Timestamp = time.Now().UTC().Format(time.RFC3339)
+		writeJSON(w, response.Status, response)
+	}
+}
+
+// callAll makes the configured downstream calls and returns the first required failure
+func (sim *simulator) callAll(ctx context.Context, svc *simService, calls []VirtualCall) ([]SimCall, error) {
+	var (
+		mu      sync.Mutex
+		results []SimCall
+		failure error
+	)
+	record := func(c VirtualCall, res SimCall) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, res)
+		if failure == nil && !c.Optional && (res.Error != "" || res.Status >= 500) {
+			failure = fmt.Errorf("%s %s returned %d", c.Service, c.Path, res.Status)
+		}
+	}
+	for _, c := range calls {
+		n := c.FanOut
+		if n == 0 {
+			n = 1
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			if !c.Parallel {
+				record(c, sim.call(ctx, svc, c))
+				continue
+			}
+			wg.Add(1)
+			go func(c VirtualCall) {
+				defer wg.Done()
+				record(c, sim.call(ctx, svc, c))
+			}(c)
+		}
+		wg.Wait()
+	}
+	return results, failure
+}
+
+func (sim *simulator) call(ctx context.Context, from *simService, c VirtualCall) SimCall {
+	to := sim.services[c.Service]
+	res := SimCall{Service: c.Service, Path: c.Path}
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, to.baseURL+c.Path, nil)
+	if err == nil {
+		if to.host != "" {
+			req.Host = to.host
+		}
+		var resp *http.Response
+		if resp, err = from.client.Do(req); err == nil {
+			resp.Body.Close()
+			res.Status = resp.StatusCode
+		}
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	res.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return res
+}
+
+// startTopology loads cfg.Topology.File and serves its virtual services
+func startTopology(ctx context.Context, cfg *Config) (*simulator, error) {
+	topo, err := loadTopology(cfg.Topology.File)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	sim, err := newSimulator(topo, exporter)
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.listen(cfg.Topology.Port); err != nil {
+		sim.close()
+		return nil, err
+	}
+	for _, svc := range topo.Services {
+		s := sim.services[svc.Name]
+		if s.host != "" {
+			log.Printf("Virtual service %s on %s (Host: %s)", svc.Name, s.baseURL, s.host)
+		} else {
+			log.Printf("Virtual service %s on %s", svc.Name, s.baseURL)
+		}
+	}
+	return sim, nil
+}
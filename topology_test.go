@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+func TestTopologyValidate(t *testing.T) {
+	if _, err := loadTopology("examples/topology.json"); err != nil {
+		t.Fatalf("example topology is invalid: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		topology string
+		err      string
+	}{
+		{"empty", `{"services": []}`, "no services"},
+		{"duplicate service", `{"services": [{"name": "a"}, {"name": "a"}]}`, "duplicate service"},
+		{"bad path", `{"services": [{"name": "a", "routes": [{"path": "x"}]}]}`, "must start with /"},
+		{"error rate", `{"services": [{"name": "a", "routes": [{"path": "/", "errorRate": 2}]}]}`, "errorRate"},
+		{"unknown route", `{"services": [{"name": "a", "routes": [{"path": "/", "calls": [{"service": "b", "path": "/"}]}]}]}`, "unknown route"},
+		{"cycle", `{"services": [
+			{"name": "a", "routes": [{"path": "/", "calls": [{"service": "b", "path": "/"}]}]},
+			{"name": "b", "routes": [{"path": "/", "calls": [{"service": "a", "path": "/"}]}]}]}`, "call cycle"},
+		{"valid", `{"services": [
+			{"name": "a", "routes": [{"path": "/", "calls": [{"service": "b", "path": "/"}, {"service": "b", "path": "/"}]}]},
+			{"name": "b", "routes": [{"path": "/", "latency": {"base": "1ms"}}]}]}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var topo Topology
+			if err := json.Unmarshal([]byte(tt.topology), &topo); err != nil {
+				t.Fatal(err)
+			}
+			checkErr(t, topo.validate(), tt.err)
+		})
+	}
+}
+
+// checkErr fails the test unless err contains want, or is nil when want is empty
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" && err != nil {
+		t.Errorf("got error %v want nil", err)
+	}
+	if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+		t.Errorf("got error %v want one containing %q", err, want)
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	var topo Topology
+	json.Unmarshal([]byte(`{"services": [
+		{"name": "edge", "port": "0", "routes": [
+			{"path": "/fanout", "calls": [{"service": "backend", "path": "/item", "fanOut": 2, "parallel": true}]},
+			{"path": "/broken", "calls": [{"service": "backend", "path": "/fail"}]}
+		]},
+		{"name": "backend", "routes": [
+			{"path": "/item", "latency": {"base": "1ms"}, "spans": [{"name": "database.query", "attributes": {"db.system": "postgresql"}}]},
+			{"path": "/fail", "errorRate": 1, "errorStatus": 503}
+		]}
+	]}`), &topo)
+	if err := topo.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	sim, err := newSimulator(&topo, exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.close()
+	if err := sim.listen("0"); err != nil {
+		t.Fatal(err)
+	}
+	edge := sim.services["edge"].baseURL
+
+	resp, err := http.Get(edge + "/fanout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sr SimResponse
+	json.NewDecoder(resp.Body).Decode(&sr)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(sr.Calls) != 2 {
+		t.Fatalf("unexpected response: %d %+v", resp.StatusCode, sr)
+	}
+	for _, c := range sr.Calls {
+		if c.Service != "backend" || c.Status != http.StatusOK {
+			t.Errorf("unexpected call result: %+v", c)
+		}
+	}
+
+	for _, svc := range sim.services {
+		svc.tp.ForceFlush(context.Background())
+	}
+	services := make(map[string]int)
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID().String() != sr.TraceID {
+			t.Errorf("span %s is not part of trace %s", span.Name, sr.TraceID)
+		}
+		for _, kv := range span.Resource.Attributes() {
+			if kv.Key == semconv.ServiceNameKey {
+				services[kv.Value.AsString()]++
+			}
+		}
+	}
+	// edge: server span + 2 client spans; backend: 2 server spans + 2 database spans
+	if services["edge"] != 3 || services["backend"] != 4 {
+		t.Errorf("unexpected spans per service: %v", services)
+	}
+
+	resp, err = http.Get(edge + "/broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&sr)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || sr.Calls[0].Status != http.StatusServiceUnavailable {
+		t.Errorf("downstream failure was not propagated: %d %+v", resp.StatusCode, sr)
+	}
+
+	// The shared listener rejects hosts that are not virtual services on it
+	req, _ := http.NewRequest("GET", sim.services["backend"].baseURL+"/item", nil)
+	req.Host = "edge"
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected response for a service on its own port: %v %v", resp, err)
+	}
+}
+
+// closeRecorder counts the spans exported before Shutdown and those that arrive too late
+type closeRecorder struct {
+	mu        sync.Mutex
+	exported  map[string]int
+	late      int
+	shutdowns int
+}
+
+func (c *closeRecorder) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, span := range spans {
+		if c.shutdowns > 0 {
+			c.late++
+			continue
+		}
+		for _, kv := range span.Resource().Attributes() {
+			if kv.Key == semconv.ServiceNameKey {
+				c.exported[kv.Value.AsString()]++
+			}
+		}
+	}
+	return nil
+}
+
+func (c *closeRecorder) Shutdown(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shutdowns++
+	return nil
+}
+
+func TestSimulatorCloseFlushesEveryService(t *testing.T) {
+	var topo Topology
+	json.Unmarshal([]byte(`{"services": [
+		{"name": "a", "routes": [{"path": "/"}]},
+		{"name": "b", "routes": [{"path": "/"}]},
+		{"name": "c", "routes": [{"path": "/"}]}
+	]}`), &topo)
+	exporter := &closeRecorder{exported: make(map[string]int)}
+	sim, err := newSimulator(&topo, exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, svc := range sim.services {
+		_, span := svc.tracer.Start(context.Background(), "final")
+		span.End()
+	}
+	sim.close()
+
+	if exporter.shutdowns != 1 || exporter.late != 0 {
+		t.Errorf("exporter shut down %d times, %d spans after shutdown", exporter.shutdowns, exporter.late)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if exporter.exported[name] != 1 {
+			t.Errorf("final span of %s was not exported: %v", name, exporter.exported)
+		}
+	}
+}
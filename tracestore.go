@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// StoredSpan is a finished span kept in memory for the admin trace store
+type StoredSpan struct {
+	TraceID    string            `json:"traceId"`
+	SpanID     string            `json:"spanId"`
+	ParentID   string            `json:"parentId,omitempty"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	Start      string            `json:"start"`
+	DurationMs float64           `json:"durationMs"`
+	Status     string            `json:"status"` // Unset, Error or Ok
+	Message    string            `json:"message,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	start, end time.Time
+}
+
+// TraceSummary describes one trace in the store
+type TraceSummary struct {
+	TraceID    string  `json:"traceId"`
+	Root       string  `json:"root"`
+	Spans      int     `json:"spans"`
+	Start      string  `json:"start"`
+	DurationMs float64 `json:"durationMs"`
+	Error      bool    `json:"error"`
+}
+
+// traceStore is a span processor that keeps the most recent finished spans, so
+// traces can be inspected on the admin listener without a tracing backend
+type traceStore struct {
+	mu    sync.Mutex
+	spans []StoredSpan // ring buffer, next is the oldest once full
+	next  int
+	full  bool
+}
+
+var traces = newTraceStore(2000)
+
+func newTraceStore(capacity int) *traceStore {
+	return &traceStore{spans: make([]StoredSpan, capacity)}
+}
+
+// resize keeps the newest spans that fit in capacity
+func (s *traceStore) resize(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if capacity == len(s.spans) {
+		return
+	}
+	kept := s.ordered()
+	if len(kept) > capacity {
+		kept = kept[len(kept)-capacity:]
+	}
+	s.spans = make([]StoredSpan, capacity)
+	copy(s.spans, kept)
+	s.next, s.full = len(kept)%max(capacity, 1), len(kept) == capacity
+}
+
+// ordered returns the stored spans, oldest first
+func (s *traceStore) ordered() []StoredSpan {
+	if !s.full {
+		return append([]StoredSpan(nil), s.spans[:s.next]...)
+	}
+	return append(append([]StoredSpan(nil), s.spans[s.next:]...), s.spans[:s.next]...)
+}
+
+func (s *traceStore) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (s *traceStore) OnEnd(span sdktrace.ReadOnlySpan) {
+	stored := StoredSpan{
+		TraceID:    span.SpanContext().TraceID().String(),
+		SpanID:     span.SpanContext().SpanID().String(),
+		Name:       span.Name(),
+		Kind:       span.SpanKind().String(),
+		Start:      span.StartTime().UTC().Format(time.RFC3339Nano),
+		DurationMs: float64(span.EndTime().Sub(span.StartTime()).Microseconds()) / 1000,
+		Status:     span.Status().Code.String(),
+		Message:    span.Status().Description,
+		start:      span.StartTime(),
+		end:        span.EndTime(),
+	}
+	if span.Parent().IsValid() {
+		stored.ParentID = span.Parent().SpanID().String()
+	}
+	if attrs := span.Attributes(); len(attrs) > 0 {
+		stored.Attributes = make(map[string]string, len(attrs))
+		for _, kv := range attrs {
+			stored.Attributes[string(kv.Key)] = kv.Value.Emit()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.spans) == 0 {
+		return
+	}
+	s.spans[s.next] = stored
+	s.next = (s.next + 1) % len(s.spans)
+	s.full = s.full || s.next == 0
+}
+
+func (s *traceStore) Shutdown(context.Context) error   { return nil }
+func (s *traceStore) ForceFlush(context.Context) error { return nil }
+
+// Traces summarizes the stored traces, most recently finished first
+func (s *traceStore) Traces(limit int) []TraceSummary {
+	s.mu.Lock()
+	spans := s.ordered()
+	s.mu.Unlock()
+
+	byTrace := make(map[string]*TraceSummary)
+	var order []string
+	var starts, ends = make(map[string]time.Time), make(map[string]time.Time)
+	for i := len(spans) - 1; i >= 0; i-- {
+		sp := spans[i]
+		t, ok := byTrace[sp.TraceID]
+		if !ok {
+			t = &TraceSummary{TraceID: sp.TraceID}
+			byTrace[sp.TraceID] = t
+			order = append(order, sp.TraceID)
+			starts[sp.TraceID], ends[sp.TraceID] = sp.start, sp.end
+		}
+		t.Spans++
+		t.Error = t.Error || sp.Status == codes.Error.String()
+		if sp.ParentID == "" || t.Root == "" {
+			t.Root = sp.Name
+		}
+		if sp.start.Before(starts[sp.TraceID]) {
+			starts[sp.TraceID] = sp.start
+		}
+		if sp.end.After(ends[sp.TraceID]) {
+			ends[sp.TraceID] = sp.end
+		}
+	}
+	if limit > 0 && len(order) > limit {
+		order = order[:limit]
+	}
+	list := make([]TraceSummary, 0, len(order))
+	for _, id := range order {
+		t := byTrace[id]
+		t.Start = starts[id].UTC().Format(time.RFC3339Nano)
+		t.DurationMs = float64(ends[id].Sub(starts[id]).Microseconds()) / 1000
+		list = append(list, *t)
+	}
+	return list
+}
+
+// Trace returns the stored spans of one trace in start order
+func (s *traceStore) Trace(traceID string) []StoredSpan {
+	s.mu.Lock()
+	spans := s.ordered()
+	s.mu.Unlock()
+
+	var list []StoredSpan
+	for _, sp := range spans {
+		if sp.TraceID == traceID {
+			list = append(list, sp)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].start.Before(list[j].start) })
+	return list
+}
+
+func registerTraceStoreHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/traces", tracesHandler)
+	mux.HandleFunc("/admin/traces/", tracesHandler)
+}
+
+// tracesHandler lists recent traces (?limit=, default 20) or, under
+// /admin/traces/{traceId}, returns the spans of one trace
+func tracesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if id := strings.TrimPrefix(r.URL.Path, "/admin/traces/"); id != r.URL.Path && id != "" {
+		spans := traces.Trace(id)
+		if len(spans) == 0 {
+			writeAdminError(w, http.StatusNotFound, "trace "+id+" not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, spans)
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeAdminError(w, http.StatusBadRequest, "invalid limit: "+v)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, traces.Traces(limit))
+}
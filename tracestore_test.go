@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceStore(t *testing.T) {
+	store := newTraceStore(4)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(store))
+	tracer := tp.Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "GET /api/hello")
+	_, child := tracer.Start(ctx, "db.query")
+	child.SetStatus(codes.Error, "timeout")
+	child.End()
+	root.End()
+	first := root.SpanContext().TraceID().String()
+
+	_, other := tracer.Start(context.Background(), "GET /version")
+	other.End()
+
+	list := store.Traces(0)
+	if len(list) != 2 || list[0].Root != "GET /version" {
+		t.Fatalf("got %+v want the newest trace first", list)
+	}
+	if got := list[1]; got.TraceID != first || got.Root != "GET /api/hello" || got.Spans != 2 || !got.Error {
+		t.Errorf("unexpected summary %+v", got)
+	}
+	if spans := store.Trace(first); len(spans) != 2 || spans[0].Name != "GET /api/hello" || spans[1].ParentID != spans[0].SpanID {
+		t.Errorf("unexpected spans %+v", spans)
+	}
+
+	// Older spans are overwritten once the buffer is full
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "filler")
+		span.End()
+	}
+	if spans := store.Trace(first); len(spans) != 0 {
+		t.Errorf("got %d spans of an evicted trace", len(spans))
+	}
+	if got := len(store.Traces(2)); got != 2 {
+		t.Errorf("got %d traces want the limit of 2", got)
+	}
+	store.resize(2)
+	if got := len(store.Traces(0)); got != 2 {
+		t.Errorf("got %d traces after shrinking to 2 spans", got)
+	}
+}
+
+func TestTracesHandler(t *testing.T) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(traces))
+	_, span := tp.Tracer("test").Start(context.Background(), "stored")
+	span.End()
+	id := span.SpanContext().TraceID().String()
+
+	tests := []struct {
+		name     string
+		target   string
+		expected int
+	}{
+		{"list", "/admin/traces?limit=5", http.StatusOK},
+		{"bad limit", "/admin/traces?limit=x", http.StatusBadRequest},
+		{"trace", "/admin/traces/" + id, http.StatusOK},
+		{"unknown trace", "/admin/traces/0123", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tracesHandler(rr, httptest.NewRequest("GET", tt.target, nil))
+			if rr.Code != tt.expected {
+				t.Errorf("got %v want %v", rr.Code, tt.expected)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	tracesHandler(rr, httptest.NewRequest("GET", "/admin/traces", nil))
+	var list []TraceSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list) == 0 || list[0].TraceID != id {
+		t.Errorf("got %s want the stored trace first: %v", rr.Body, err)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var wsConfig atomic.Pointer[WebSocketConfig]
+
+func init() {
+	wsConfig.Store(&WebSocketConfig{})
+}
+
+// validate rejects settings that would panic the ping ticker or time every connection out
+func (cfg *WebSocketConfig) validate() error {
+	if cfg.IdleTimeout <= 0 || cfg.PingInterval <= 0 {
+		return fmt.Errorf("websocket: idle timeout (%s) and ping interval (%s) must be positive", cfg.IdleTimeout, cfg.PingInterval)
+	}
+	return nil
+}
+
+// WSMessage is the JSON frame sent to WebSocket clients
+type WSMessage struct {
+	Type      string `json:"type"` // echo, message, join or leave
+	Room      string `json:"room,omitempty"`
+	From      string `json:"from,omitempty"`
+	Data      string `json:"data,omitempty"`
+	Seq       int64  `json:"seq"`
+	Timestamp string `json:"timestamp"`
+	TraceID   string `json:"traceId,omitempty"`
+}
+
+type WebSocketMetrics struct {
+	Active      int64            `json:"active"`
+	ByEndpoint  map[string]int64 `json:"byEndpoint"`
+	Total       int64            `json:"total"`
+	Rejected    int64            `json:"rejected"`
+	MessagesIn  int64            `json:"messagesIn"`
+	MessagesOut int64            `json:"messagesOut"`
+	Dropped     int64            `json:"dropped"` // broadcast frames dropped for slow clients
+	Rooms       map[string]int   `json:"rooms,omitempty"`
+}
+
+var wsStats struct {
+	active, total, rejected          atomic.Int64
+	messagesIn, messagesOut, dropped atomic.Int64
+	echo, broadcast                  atomic.Int64
+}
+
+// wsConn wraps a connection with its span, keepalive and a single writer goroutine
+type wsConn struct {
+	conn *websocket.Conn
+	cfg  *WebSocketConfig
+	span trace.Span
+	id   string
+	send chan []byte
+	done chan struct{}
+	seq  atomic.Int64
+}
+
+// upgradeWebSocket enforces the connection limit and origin policy, then upgrades the request
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, endpoint string) (*wsConn, context.Context, bool) {
+	cfg := wsConfig.Load()
+	if cfg.MaxConnections > 0 && wsStats.active.Load() >= int64(cfg.MaxConnections) {
+		wsStats.rejected.Add(1)
+		writeProblem(w, r, http.StatusServiceUnavailable, "too many WebSocket connections")
+		return nil, nil, false
+	}
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     func(r *http.Request) bool { return cfg.originAllowed(r) },
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		log.Printf("[WARN] WebSocket upgrade failed, path: %s, error: %v, traceId: %s", r.URL.Path, err, getTraceID(r.Context()))
+		return nil, nil, false
+	}
+	conn.SetReadLimit(cfg.MaxMessageBytes)
+
+	ctx, span := otel.Tracer("demo-app").Start(r.Context(), "websocket "+endpoint,
+		trace.WithAttributes(
+			attribute.String("websocket.endpoint", endpoint),
+			attribute.String("client.address", r.RemoteAddr),
+		))
+	wc := &wsConn{
+		conn: conn,
+		cfg:  cfg,
+		span: span,
+		id:   span.SpanContext().SpanID().String(),
+		send: make(chan []byte, 32),
+		done: make(chan struct{}),
+	}
+	wsStats.active.Add(1)
+	wsStats.total.Add(1)
+	log.Printf("[INFO] WebSocket connected, endpoint: %s, id: %s, traceId: %s", endpoint, wc.id, getTraceID(ctx))
+	return wc, ctx, true
+}
+
+// originAllowed accepts same-origin requests, non-browser clients and the configured origins
+func (cfg *WebSocketConfig) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return containsFold(cfg.AllowedOrigins, origin) || contains(cfg.AllowedOrigins, "*")
+}
+
+// writeLoop owns all writes to the connection: queued frames and keepalive pings
+func (wc *wsConn) writeLoop() {
+	ticker := time.NewTicker(wc.cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-wc.send:
+			wc.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := wc.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				wc.conn.Close()
+				return
+			}
+			wsStats.messagesOut.Add(1)
+		case <-ticker.C:
+			if err := wc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				wc.conn.Close()
+				return
+			}
+		case <-wc.done:
+			return
+		}
+	}
+}
+
+// readLoop extends the idle deadline on every frame and pong, and hands text frames to onMessage
+func (wc *wsConn) readLoop(onMessage func(data []byte)) error {
+	wc.conn.SetReadDeadline(time.Now().Add(wc.cfg.IdleTimeout))
+	wc.conn.SetPongHandler(func(string) error {
+		return wc.conn.SetReadDeadline(time.Now().Add(wc.cfg.IdleTimeout))
+	})
+	for {
+		_, data, err := wc.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		wc.conn.SetReadDeadline(time.Now().Add(wc.cfg.IdleTimeout))
+		wsStats.messagesIn.Add(1)
+		wc.span.AddEvent("websocket.message.received", trace.WithAttributes(attribute.Int("messaging.message.body.size", len(data))))
+		onMessage(data)
+	}
+}
+
+// enqueue queues a frame without blocking; frames for a client that cannot keep up are dropped
+func (wc *wsConn) enqueue(msg WSMessage) bool {
+	msg.Seq = wc.seq.Add(1)
+	data, _ := json.Marshal(msg)
+	select {
+	case wc.send <- data:
+		wc.span.AddEvent("websocket.message.sent", trace.WithAttributes(
+			attribute.String("websocket.message.type", msg.Type),
+			attribute.Int("messaging.message.body.size", len(data)),
+		))
+		return true
+	default:
+		wsStats.dropped.Add(1)
+		return false
+	}
+}
+
+func (wc *wsConn) close(endpoint string, err error) {
+	close(wc.done)
+	wc.conn.Close()
+	wsStats.active.Add(-1)
+	if err != nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		wc.span.SetAttributes(attribute.String("websocket.close_reason", err.Error()))
+	}
+	wc.span.End()
+	log.Printf("[INFO] WebSocket disconnected, endpoint: %s, id: %s, reason: %v", endpoint, wc.id, err)
+}
+
+// wsEchoHandler echoes every text frame back with a timestamp and the connection's trace ID
+func wsEchoHandler(w http.ResponseWriter, r *http.Request) {
+	wc, ctx, ok := upgradeWebSocket(w, r, "/ws/echo")
+	if !ok {
+		return
+	}
+	wsStats.echo.Add(1)
+	defer wsStats.echo.Add(-1)
+	go wc.writeLoop()
+
+	traceID := getTraceID(ctx)
+	err := wc.readLoop(func(data []byte) {
+		wc.enqueue(WSMessage{Type: "echo", Data: string(data), Timestamp: time.Now().UTC().Format(time.RFC3339Nano), TraceID: traceID})
+	})
+	wc.close("/ws/echo", err)
+}
+
+// wsHub fans broadcast frames out to every connection in a room
+type wsHub struct {
+	mu    sync.Mutex
+	rooms map[string]map[*wsConn]struct{}
+}
+
+var hub = &wsHub{rooms: make(map[string]map[*wsConn]struct{})}
+
+func (h *wsHub) join(room string, wc *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[*wsConn]struct{})
+	}
+	h.rooms[room][wc] = struct{}{}
+}
+
+func (h *wsHub) leave(room string, wc *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.rooms[room], wc)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// broadcast sends msg to all members of room and returns how many received it
+func (h *wsHub) broadcast(room string, msg WSMessage) int {
+	h.mu.Lock()
+	members := make([]*wsConn, 0, len(h.rooms[room]))
+	for wc := range h.rooms[room] {
+		members = append(members, wc)
+	}
+	h.mu.Unlock()
+
+	delivered := 0
+	for _, wc := range members {
+		if wc.enqueue(msg) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+func (h *wsHub) sizes() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sizes := make(map[string]int, len(h.rooms))
+	for room, members := range h.rooms {
+		sizes[room] = len(members)
+	}
+	return sizes
+}
+
+// wsBroadcastHandler relays every text frame to all clients in the same ?room= (default "lobby")
+func wsBroadcastHandler(w http.ResponseWriter, r *http.Request) {
+	room := r.URL.Query().Get("room")
+	if room == "" {
+		room = "lobby"
+	}
+	wc, ctx, ok := upgradeWebSocket(w, r, "/ws/broadcast")
+	if !ok {
+		return
+	}
+	wsStats.broadcast.Add(1)
+	defer wsStats.broadcast.Add(-1)
+	wc.span.SetAttributes(attribute.String("websocket.room", room))
+	go wc.writeLoop()
+
+	traceID := getTraceID(ctx)
+	now := func() string { return time.Now().UTC().Format(time.RFC3339Nano) }
+	hub.join(room, wc)
+	hub.broadcast(room, WSMessage{Type: "join", Room: room, From: wc.id, Timestamp: now(), TraceID: traceID})
+
+	err := wc.readLoop(func(data []byte) {
+		n := hub.broadcast(room, WSMessage{Type: "message", Room: room, From: wc.id, Data: string(data), Timestamp: now(), TraceID: traceID})
+		wc.span.AddEvent("websocket.broadcast", trace.WithAttributes(attribute.Int("websocket.recipients", n)))
+	})
+
+	hub.leave(room, wc)
+	hub.broadcast(room, WSMessage{Type: "leave", Room: room, From: wc.id, Timestamp: now(), TraceID: traceID})
+	wc.close("/ws/broadcast", err)
+}
+
+func webSocketMetrics() WebSocketMetrics {
+	return WebSocketMetrics{
+		Active:      wsStats.active.Load(),
+		ByEndpoint:  map[string]int64{"/ws/echo": wsStats.echo.Load(), "/ws/broadcast": wsStats.broadcast.Load()},
+		Total:       wsStats.total.Load(),
+		Rejected:    wsStats.rejected.Load(),
+		MessagesIn:  wsStats.messagesIn.Load(),
+		MessagesOut: wsStats.messagesOut.Load(),
+		Dropped:     wsStats.dropped.Load(),
+		Rooms:       hub.sizes(),
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+func setupWebSocket(t *testing.T, cfg WebSocketConfig) string {
+	t.Helper()
+	old := wsConfig.Load()
+	wsConfig.Store(&cfg)
+	t.Cleanup(func() { wsConfig.Store(old) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/echo", wsEchoHandler)
+	mux.HandleFunc("/ws/broadcast", wsBroadcastHandler)
+	// Run through the wrapping middleware to make sure hijacking still works
+	srv := httptest.NewServer(otelhttp.NewHandler(compressResponses(secureHeaders(mux)), "test"))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func readWSMessage(t *testing.T, conn *websocket.Conn) WSMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg WSMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	url := setupWebSocket(t, loadConfig().WebSocket)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/ws/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i, text := range []string{"one", "two"} {
+		conn.WriteMessage(websocket.TextMessage, []byte(text))
+		msg := readWSMessage(t, conn)
+		if msg.Type != "echo" || msg.Data != text || msg.Seq != int64(i+1) || msg.Timestamp == "" {
+			t.Errorf("unexpected echo frame: %+v", msg)
+		}
+	}
+
+	if m := webSocketMetrics(); m.Active < 1 || m.ByEndpoint["/ws/echo"] < 1 {
+		t.Errorf("unexpected WebSocket metrics: %+v", m)
+	}
+}
+
+func TestWebSocketBroadcast(t *testing.T) {
+	url := setupWebSocket(t, loadConfig().WebSocket)
+
+	alice, _, err := websocket.DefaultDialer.Dial(url+"/ws/broadcast?room=ops", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alice.Close()
+	if msg := readWSMessage(t, alice); msg.Type != "join" {
+		t.Fatalf("expected own join frame, got %+v", msg)
+	}
+
+	bob, _, err := websocket.DefaultDialer.Dial(url+"/ws/broadcast?room=ops", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bob.Close()
+	readWSMessage(t, bob)
+	if msg := readWSMessage(t, alice); msg.Type != "join" || msg.Room != "ops" {
+		t.Fatalf("expected bob's join frame, got %+v", msg)
+	}
+
+	other, _, err := websocket.DefaultDialer.Dial(url+"/ws/broadcast?room=other", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	readWSMessage(t, other)
+
+	bob.WriteMessage(websocket.TextMessage, []byte("hello room"))
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if msg := readWSMessage(t, conn); msg.Type != "message" || msg.Data != "hello room" {
+			t.Errorf("unexpected broadcast frame: %+v", msg)
+		}
+	}
+
+	other.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := other.ReadMessage(); err == nil {
+		t.Error("message leaked into another room")
+	}
+
+	bob.Close()
+	if msg := readWSMessage(t, alice); msg.Type != "leave" {
+		t.Errorf("expected leave frame, got %+v", msg)
+	}
+}
+
+func TestWebSocketLimits(t *testing.T) {
+	cfg := loadConfig().WebSocket
+	cfg.IdleTimeout = 200 * time.Millisecond
+	cfg.PingInterval = time.Hour
+	url := setupWebSocket(t, cfg)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/ws/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("idle connection was not closed")
+	}
+
+	header := http.Header{"Origin": {"https://evil.example.com"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"/ws/echo", header); err == nil {
+		t.Error("cross-origin upgrade was accepted")
+	} else if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unexpected response to cross-origin upgrade: %v", resp)
+	}
+
+	keep, _, err := websocket.DefaultDialer.Dial(url+"/ws/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keep.Close()
+	keep.WriteMessage(websocket.TextMessage, []byte("ready"))
+	readWSMessage(t, keep)
+	cfg.MaxConnections = int(webSocketMetrics().Active)
+	wsConfig.Store(&cfg)
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"/ws/echo", nil); err == nil {
+		t.Error("connection over the limit was accepted")
+	} else if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected response over the limit: %v", resp)
+	}
+}
+
+func TestWebSocketConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   func(*WebSocketConfig)
+		valid bool
+	}{
+		{"defaults", func(*WebSocketConfig) {}, true},
+		{"zero idle timeout", func(c *WebSocketConfig) { c.IdleTimeout = 0 }, false},
+		{"negative ping interval", func(c *WebSocketConfig) { c.PingInterval = -time.Second }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadConfig()
+			tt.cfg(&cfg.WebSocket)
+			if err := cfg.WebSocket.validate(); (err == nil) != tt.valid {
+				t.Errorf("validate() = %v, want valid %t", err, tt.valid)
+			}
+			if err := applyConfig(cfg); (err == nil) != tt.valid {
+				t.Errorf("applyConfig() = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+	applyConfig(loadConfig())
+}